@@ -34,6 +34,9 @@ const (
 )
 const STDALL = STDOUT | STDERR
 
+// DefaultTail is the number of lines replayed when a log stream is opened
+const DefaultTail = 300
+
 func (s StdType) String() string {
 	switch s {
 	case STDOUT:
@@ -47,6 +50,20 @@ func (s StdType) String() string {
 	}
 }
 
+// ParseStdType is the opposite of String, it returns UNKNOWN for names it doesn't know
+func ParseStdType(name string) StdType {
+	switch name {
+	case "stdout":
+		return STDOUT
+	case "stderr":
+		return STDERR
+	case "all":
+		return STDALL
+	default:
+		return UNKNOWN
+	}
+}
+
 type DockerCLI interface {
 	ContainerList(context.Context, container.ListOptions) ([]types.Container, error)
 	ContainerLogs(context.Context, string, container.LogsOptions) (io.ReadCloser, error)
@@ -63,9 +80,10 @@ type DockerCLI interface {
 type Client interface {
 	ListContainers() ([]Container, error)
 	FindContainer(string) (Container, error)
-	ContainerLogs(context.Context, string, string, StdType) (io.ReadCloser, error)
+	ContainerLogs(context.Context, string, string, int, StdType, bool) (io.ReadCloser, error)
+	ContainerLogsTail(context.Context, string, int, StdType, bool) (io.ReadCloser, error)
 	Events(context.Context, chan<- ContainerEvent) error
-	ContainerLogsBetweenDates(context.Context, string, time.Time, time.Time, StdType) (io.ReadCloser, error)
+	ContainerLogsBetweenDates(context.Context, string, time.Time, time.Time, StdType, bool) (io.ReadCloser, error)
 	ContainerStats(context.Context, string, chan<- ContainerStat) error
 	Ping(context.Context) (types.Ping, error)
 	Host() *Host
@@ -177,6 +195,14 @@ func (d *httpClient) FindContainer(id string) (Container, error) {
 
 	if json, err := d.cli.ContainerInspect(context.Background(), container.ID); err == nil {
 		container.Tty = json.Config.Tty
+		if json.ContainerJSONBase != nil && json.State != nil {
+			container.Started, _ = time.Parse(time.RFC3339Nano, json.State.StartedAt)
+			container.Finished, _ = time.Parse(time.RFC3339Nano, json.State.FinishedAt)
+			container.ExitCode = json.State.ExitCode
+		}
+		if json.ContainerJSONBase != nil && json.HostConfig != nil {
+			container.LogDriver = json.HostConfig.LogConfig.Type
+		}
 	} else {
 		return container, err
 	}
@@ -288,26 +314,48 @@ func (d *httpClient) ContainerStats(ctx context.Context, id string, stats chan<-
 	}
 }
 
-func (d *httpClient) ContainerLogs(ctx context.Context, id string, since string, stdType StdType) (io.ReadCloser, error) {
-	log.WithField("id", id).WithField("since", since).WithField("stdType", stdType).Debug("streaming logs for container")
+// logsOptions are the options shared by every request for logs. Events are parsed from the timestamps Docker
+// puts in front of each line. details asks Docker for the attributes configured with --log-opt labels, env or tag
+// on every line.
+func logsOptions(stdType StdType, details bool) container.LogsOptions {
+	return container.LogsOptions{
+		ShowStdout: stdType&STDOUT != 0,
+		ShowStderr: stdType&STDERR != 0,
+		Timestamps: true,
+		Details:    details,
+	}
+}
+
+func (d *httpClient) ContainerLogs(ctx context.Context, id string, since string, tail int, stdType StdType, details bool) (io.ReadCloser, error) {
+	log.WithField("id", id).WithField("since", since).WithField("tail", tail).WithField("stdType", stdType).Debug("streaming logs for container")
 
 	if since != "" {
 		if millis, err := strconv.ParseInt(since, 10, 64); err == nil {
-			since = time.UnixMicro(millis).Add(time.Millisecond).Format(time.RFC3339Nano)
+			since = time.UnixMilli(millis).Add(time.Millisecond).Format(time.RFC3339Nano)
 		} else {
 			log.WithError(err).Debug("unable to parse since")
 		}
 	}
 
-	options := container.LogsOptions{
-		ShowStdout: stdType&STDOUT != 0,
-		ShowStderr: stdType&STDERR != 0,
-		Follow:     true,
-		Tail:       "300",
-		Timestamps: true,
-		Since:      since,
+	options := logsOptions(stdType, details)
+	options.Follow = true
+	options.Tail = strconv.Itoa(tail)
+	options.Since = since
+
+	reader, err := d.cli.ContainerLogs(ctx, id, options)
+	if err != nil {
+		return nil, err
 	}
 
+	return reader, nil
+}
+
+func (d *httpClient) ContainerLogsTail(ctx context.Context, id string, tail int, stdType StdType, details bool) (io.ReadCloser, error) {
+	log.WithField("id", id).WithField("tail", tail).WithField("stdType", stdType).Debug("fetching last logs for container")
+
+	options := logsOptions(stdType, details)
+	options.Tail = strconv.Itoa(tail)
+
 	reader, err := d.cli.ContainerLogs(ctx, id, options)
 	if err != nil {
 		return nil, err
@@ -339,14 +387,10 @@ func (d *httpClient) Events(ctx context.Context, messages chan<- ContainerEvent)
 
 }
 
-func (d *httpClient) ContainerLogsBetweenDates(ctx context.Context, id string, from time.Time, to time.Time, stdType StdType) (io.ReadCloser, error) {
-	options := container.LogsOptions{
-		ShowStdout: stdType&STDOUT != 0,
-		ShowStderr: stdType&STDERR != 0,
-		Timestamps: true,
-		Since:      from.Format(time.RFC3339Nano),
-		Until:      to.Format(time.RFC3339Nano),
-	}
+func (d *httpClient) ContainerLogsBetweenDates(ctx context.Context, id string, from time.Time, to time.Time, stdType StdType, details bool) (io.ReadCloser, error) {
+	options := logsOptions(stdType, details)
+	options.Since = from.Format(time.RFC3339Nano)
+	options.Until = to.Format(time.RFC3339Nano)
 
 	log.Debugf("fetching logs from Docker with option: %+v", options)
 
@@ -374,6 +418,12 @@ func (d *httpClient) SystemInfo() system.Info {
 	return d.info
 }
 
+// IsLogDriverUnsupported reports whether reading logs failed because the container's logging driver, such as
+// awslogs, can't be read back through the Docker API
+func IsLogDriverUnsupported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "logging driver does not support reading")
+}
+
 var PARENTHESIS_RE = regexp.MustCompile(`\(([a-zA-Z]+)\)`)
 
 func findBetweenParentheses(s string) string {
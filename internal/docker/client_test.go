@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"strings"
+	"time"
 
 	"testing"
 
@@ -153,13 +155,63 @@ func Test_dockerClient_ContainerLogs_happy(t *testing.T) {
 	proxy.On("ContainerLogs", mock.Anything, id, options).Return(reader, nil)
 
 	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
-	logReader, _ := client.ContainerLogs(context.Background(), id, "since", STDALL)
+	logReader, _ := client.ContainerLogs(context.Background(), id, "since", DefaultTail, STDALL, false)
 
 	actual, _ := io.ReadAll(logReader)
 	assert.Equal(t, string(b), string(actual), "message doesn't match expected")
 	proxy.AssertExpectations(t)
 }
 
+func Test_dockerClient_ContainerLogs_since_millis(t *testing.T) {
+	id := "123456"
+
+	proxy := new(mockedProxy)
+	reader := io.NopCloser(bytes.NewReader(nil))
+	since := time.UnixMilli(1589396137772).Add(time.Millisecond).Format(time.RFC3339Nano)
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true, Tail: "300", Timestamps: true, Since: since}
+	proxy.On("ContainerLogs", mock.Anything, id, options).Return(reader, nil)
+
+	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
+	_, err := client.ContainerLogs(context.Background(), id, "1589396137772", DefaultTail, STDALL, false)
+
+	assert.NoError(t, err)
+	proxy.AssertExpectations(t)
+}
+
+func Test_dockerClient_ContainerLogsTail(t *testing.T) {
+	id := "123456"
+
+	proxy := new(mockedProxy)
+	reader := io.NopCloser(strings.NewReader("INFO Testing logs..."))
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: false, Tail: "10", Timestamps: true}
+	proxy.On("ContainerLogs", mock.Anything, id, options).Return(reader, nil)
+
+	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
+	logReader, err := client.ContainerLogsTail(context.Background(), id, 10, STDOUT, false)
+
+	assert.NoError(t, err)
+	actual, _ := io.ReadAll(logReader)
+	assert.Equal(t, "INFO Testing logs...", string(actual), "message doesn't match expected")
+	proxy.AssertExpectations(t)
+}
+
+func Test_dockerClient_ContainerLogsBetweenDates_details(t *testing.T) {
+	id := "123456"
+	from := time.Date(2020, 5, 13, 18, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	proxy := new(mockedProxy)
+	reader := io.NopCloser(strings.NewReader(""))
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true, Details: true, Since: from.Format(time.RFC3339Nano), Until: to.Format(time.RFC3339Nano)}
+	proxy.On("ContainerLogs", mock.Anything, id, options).Return(reader, nil)
+
+	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
+	_, err := client.ContainerLogsBetweenDates(context.Background(), id, from, to, STDALL, true)
+
+	assert.NoError(t, err)
+	proxy.AssertExpectations(t)
+}
+
 func Test_dockerClient_ContainerLogs_error(t *testing.T) {
 	id := "123456"
 	proxy := new(mockedProxy)
@@ -168,7 +220,7 @@ func Test_dockerClient_ContainerLogs_error(t *testing.T) {
 
 	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
 
-	reader, err := client.ContainerLogs(context.Background(), id, "", STDALL)
+	reader, err := client.ContainerLogs(context.Background(), id, "", DefaultTail, STDALL, false)
 
 	assert.Nil(t, reader, "reader should be nil")
 	assert.Error(t, err, "error should have been returned")
@@ -190,7 +242,13 @@ func Test_dockerClient_FindContainer_happy(t *testing.T) {
 	proxy := new(mockedProxy)
 	proxy.On("ContainerList", mock.Anything, mock.Anything).Return(containers, nil)
 
-	json := types.ContainerJSON{Config: &container.Config{Tty: false}}
+	json := types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			State:      &types.ContainerState{ExitCode: 137, FinishedAt: "2020-05-13T18:55:37.772853839Z"},
+			HostConfig: &container.HostConfig{LogConfig: container.LogConfig{Type: "awslogs"}},
+		},
+		Config: &container.Config{Tty: false},
+	}
 	proxy.On("ContainerInspect", mock.Anything, "abcdefghijkl").Return(json, nil)
 
 	client := &httpClient{proxy, filters.NewArgs(), &Host{ID: "localhost"}, system.Info{}}
@@ -199,6 +257,9 @@ func Test_dockerClient_FindContainer_happy(t *testing.T) {
 	require.NoError(t, err, "error should not be thrown")
 
 	assert.Equal(t, container.ID, "abcdefghijkl")
+	assert.Equal(t, 137, container.ExitCode)
+	assert.Equal(t, time.Date(2020, 5, 13, 18, 55, 37, 772853839, time.UTC), container.Finished)
+	assert.Equal(t, "awslogs", container.LogDriver)
 
 	proxy.AssertExpectations(t)
 }
@@ -27,6 +27,19 @@ type EventGenerator struct {
 	buffer chan *LogEvent
 	tty    bool
 	wg     sync.WaitGroup
+
+	levelPattern *regexp.Regexp
+	details      bool
+}
+
+// EventGeneratorOptions tunes an EventGenerator. The zero value behaves like NewEventGenerator.
+type EventGeneratorOptions struct {
+	// BufferSize is how many events are buffered on Events, see NewEventGeneratorWithBuffer
+	BufferSize int
+	// LevelPattern extracts the level of a line instead of the built-in guessing, see ExtractLevel
+	LevelPattern *regexp.Regexp
+	// Details parses the attributes Docker sends for readers opened with details into LogEvent.Details
+	Details bool
 }
 
 var bufPool = sync.Pool{
@@ -38,12 +51,24 @@ var bufPool = sync.Pool{
 var ErrBadHeader = fmt.Errorf("dozzle/docker: unable to read header")
 
 func NewEventGenerator(reader io.Reader, tty bool) *EventGenerator {
+	return NewEventGeneratorWithOptions(reader, tty, EventGeneratorOptions{})
+}
+
+// NewEventGeneratorWithBuffer is like NewEventGenerator with size events buffered on Events. A bigger buffer lets
+// the parser run ahead of a consumer that reads in bursts at the cost of holding more events in memory.
+func NewEventGeneratorWithBuffer(reader io.Reader, tty bool, size int) *EventGenerator {
+	return NewEventGeneratorWithOptions(reader, tty, EventGeneratorOptions{BufferSize: size})
+}
+
+func NewEventGeneratorWithOptions(reader io.Reader, tty bool, options EventGeneratorOptions) *EventGenerator {
 	generator := &EventGenerator{
-		reader: bufio.NewReader(reader),
-		buffer: make(chan *LogEvent, 100),
-		Errors: make(chan error, 1),
-		Events: make(chan *LogEvent),
-		tty:    tty,
+		reader:       bufio.NewReader(reader),
+		buffer:       make(chan *LogEvent, 100),
+		Errors:       make(chan error, 1),
+		Events:       make(chan *LogEvent, options.BufferSize),
+		tty:          tty,
+		levelPattern: options.LevelPattern,
+		details:      options.Details,
 	}
 	generator.wg.Add(2)
 	go generator.consumeReader()
@@ -70,7 +95,7 @@ func (g *EventGenerator) processBuffer() {
 			next = g.peek()
 		}
 
-		checkPosition(current, next)
+		checkPosition(current, next, g.guessLevel(current))
 
 		g.Events <- current
 	}
@@ -81,9 +106,14 @@ func (g *EventGenerator) consumeReader() {
 	for {
 		message, streamType, readerError := readEvent(g.reader, g.tty)
 		if message != "" {
+			var details map[string]string
+			if g.details {
+				message, details = splitLogDetails(message)
+			}
 			logEvent := createEvent(message, streamType)
+			logEvent.Details = details
 
-			logEvent.Level = guessLogLevel(logEvent)
+			logEvent.Level = g.guessLevel(logEvent)
 			g.buffer <- logEvent
 		}
 
@@ -208,8 +238,17 @@ func createEvent(message string, streamType StdType) *LogEvent {
 	return logEvent
 }
 
-func checkPosition(currentEvent *LogEvent, nextEvent *LogEvent) {
-	currentLevel := guessLogLevel(currentEvent)
+// guessLevel uses the level pattern when one is set and it matches, otherwise the built-in guessing
+func (g *EventGenerator) guessLevel(logEvent *LogEvent) string {
+	if g.levelPattern != nil {
+		if level := ExtractLevel(g.levelPattern, logEvent); level != "" {
+			return level
+		}
+	}
+	return guessLogLevel(logEvent)
+}
+
+func checkPosition(currentEvent *LogEvent, nextEvent *LogEvent, currentLevel string) {
 	if nextEvent != nil {
 		if currentEvent.IsCloseToTime(nextEvent) && currentLevel != "" && !nextEvent.HasLevel() {
 			currentEvent.Position = START
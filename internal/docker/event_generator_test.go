@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
@@ -36,6 +38,26 @@ func TestEventGenerator_Events_non_tty(t *testing.T) {
 	assert.Equal(t, input, event.Message)
 }
 
+func TestEventGenerator_Events_level_pattern(t *testing.T) {
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z <CRIT> disk full\n", STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO no pattern\n", STDOUT)...)
+
+	g := NewEventGeneratorWithOptions(bytes.NewReader(data), false, EventGeneratorOptions{LevelPattern: regexp.MustCompile(`^<(\w+)>`)})
+	assert.Equal(t, "crit", (<-g.Events).Level)
+	assert.Equal(t, "info", (<-g.Events).Level, "Expected the built-in guessing when the pattern doesn't match")
+}
+
+func TestEventGenerator_Events_details(t *testing.T) {
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z com.example.env=prod,tag=api%2Cv2 INFO started\n", STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z  INFO no details\n", STDOUT)...)
+
+	g := NewEventGeneratorWithOptions(bytes.NewReader(data), false, EventGeneratorOptions{Details: true})
+	event := <-g.Events
+	assert.Equal(t, "INFO started", event.Message)
+	assert.Equal(t, map[string]string{"com.example.env": "prod", "tag": "api,v2"}, event.Details)
+	event = <-g.Events
+	assert.Equal(t, "INFO no details", event.Message)
+	assert.Nil(t, event.Details)
+}
+
 func TestEventGenerator_Events_non_tty_close_channel(t *testing.T) {
 	input := "example input"
 	reader := bytes.NewReader(makeMessage(input, STDOUT))
@@ -154,3 +176,26 @@ func Benchmark_readEvent(b *testing.B) {
 		readEvent(reader, true)
 	}
 }
+
+func Benchmark_eventGenerator(b *testing.B) {
+	var data []byte
+	for i := 0; i < 10_000; i++ {
+		data = append(data, makeMessage(fmt.Sprintf("2020-05-13T18:55:37.772853839Z INFO line %d\n", i), STDOUT)...)
+	}
+
+	for _, size := range []int{0, 100, 1000} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				g := NewEventGeneratorWithBuffer(bytes.NewReader(data), false, size)
+				// simulate a consumer that reads in bursts, like a stream that blocks on every flush to the client
+				n := 0
+				for range g.Events {
+					if n++; n%100 == 0 {
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}
+		})
+	}
+}
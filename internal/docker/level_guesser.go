@@ -23,7 +23,7 @@ func init() {
 func guessLogLevel(logEvent *LogEvent) string {
 	switch value := logEvent.Message.(type) {
 	case string:
-		value = stripANSI(value)
+		value = StripANSI(value)
 		for _, level := range logLevels {
 			if plainLevels[level].MatchString(value) {
 				return level
@@ -55,3 +55,25 @@ func guessLogLevel(logEvent *LogEvent) string {
 
 	return ""
 }
+
+// ExtractLevel returns the lower cased level that pattern finds in a plain text message. The level is the group
+// named level, or else the first group, or else the whole match. It returns "" when the pattern doesn't match.
+func ExtractLevel(pattern *regexp.Regexp, logEvent *LogEvent) string {
+	value, ok := logEvent.Message.(string)
+	if !ok {
+		return ""
+	}
+
+	matches := pattern.FindStringSubmatch(StripANSI(value))
+	if matches == nil {
+		return ""
+	}
+
+	index := 0
+	if named := pattern.SubexpIndex("level"); named > 0 {
+		index = named
+	} else if len(matches) > 1 {
+		index = 1
+	}
+	return strings.ToLower(matches[index])
+}
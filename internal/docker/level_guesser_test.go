@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"regexp"
 	"testing"
 )
 
@@ -37,3 +38,25 @@ func TestGuessLogLevel(t *testing.T) {
 	}
 
 }
+
+func TestExtractLevel(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		input    string
+		expected string
+	}{
+		{`^<(?P<level>\w+)>`, "<CRIT> disk full", "crit"},
+		{`^\d+ (\w)\b`, "1589396137 W low memory", "w"},
+		{`(?i)severe`, "SEVERE: broken", "severe"},
+		{`^<(\w+)>`, "ERROR: no brackets", ""},
+	}
+
+	for _, test := range tests {
+		logEvent := &LogEvent{
+			Message: test.input,
+		}
+		if level := ExtractLevel(regexp.MustCompile(test.pattern), logEvent); level != test.expected {
+			t.Errorf("ExtractLevel(%s, %s) = %s, want %s", test.pattern, test.input, level, test.expected)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package docker
+
+import (
+	"net/url"
+	"strings"
+)
+
+// splitLogDetails removes the attributes Docker adds after the timestamp of each line when details are
+// requested. They are comma separated and query escaped, like "com.example.env=prod,tag=api".
+func splitLogDetails(line string) (string, map[string]string) {
+	timestamp, rest, found := strings.Cut(line, " ")
+	if !found {
+		return line, nil
+	}
+	attributes, message, _ := strings.Cut(rest, " ")
+	attributes = strings.TrimSuffix(attributes, "\n")
+	if attributes == "" {
+		return timestamp + " " + message, nil
+	}
+
+	details := make(map[string]string)
+	for _, pair := range strings.Split(attributes, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return line, nil
+		}
+		key, keyErr := url.QueryUnescape(key)
+		value, valueErr := url.QueryUnescape(value)
+		if keyErr != nil || valueErr != nil {
+			return line, nil
+		}
+		details[key] = value
+	}
+	return timestamp + " " + message, details
+}
@@ -8,6 +8,7 @@ const ansi = "[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)
 
 var re = regexp.MustCompile(ansi)
 
-func stripANSI(str string) string {
+// StripANSI removes ANSI color and cursor escape sequences from str
+func StripANSI(str string) string {
 	return re.ReplaceAllString(str, "")
 }
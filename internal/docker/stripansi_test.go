@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"no colors here", "no colors here"},
+		{"\x1b[31mred\x1b[0m", "red"},
+		{"\x1b[1;32mbold green\x1b[0m text", "bold green text"},
+		{"\x1b[38;5;208morange\x1b[39m", "orange"},
+		{"\x1b[38;2;255;0;0mtrue color\x1b[0m", "true color"},
+		{"\x1b[2K\x1b[1Gprogress", "progress"},
+		{"\x1b[?25lhidden cursor\x1b[?25h", "hidden cursor"},
+		{"\x1b]0;title\x07content", "content"},
+	}
+
+	for _, test := range tests {
+		if actual := StripANSI(test.input); actual != test.expected {
+			t.Errorf("StripANSI(%q) = %q, want %q", test.input, actual, test.expected)
+		}
+	}
+}
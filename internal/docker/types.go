@@ -2,26 +2,31 @@ package docker
 
 import (
 	"math"
+	"time"
 
 	"github.com/amir20/dozzle/internal/utils"
 )
 
 // Container represents an internal representation of docker containers
 type Container struct {
-	ID      string                           `json:"id"`
-	Names   []string                         `json:"names"`
-	Name    string                           `json:"name"`
-	Image   string                           `json:"image"`
-	ImageID string                           `json:"imageId"`
-	Command string                           `json:"command"`
-	Created int64                            `json:"created"`
-	State   string                           `json:"state"`
-	Status  string                           `json:"status"`
-	Health  string                           `json:"health,omitempty"`
-	Host    string                           `json:"host,omitempty"`
-	Tty     bool                             `json:"-"`
-	Labels  map[string]string                `json:"labels,omitempty"`
-	Stats   *utils.RingBuffer[ContainerStat] `json:"stats,omitempty"`
+	ID        string                           `json:"id"`
+	Names     []string                         `json:"names"`
+	Name      string                           `json:"name"`
+	Image     string                           `json:"image"`
+	ImageID   string                           `json:"imageId"`
+	Command   string                           `json:"command"`
+	Created   int64                            `json:"created"`
+	State     string                           `json:"state"`
+	Status    string                           `json:"status"`
+	Health    string                           `json:"health,omitempty"`
+	Host      string                           `json:"host,omitempty"`
+	Tty       bool                             `json:"-"`
+	Started   time.Time                        `json:"-"`
+	Finished  time.Time                        `json:"-"`
+	ExitCode  int                              `json:"-"`
+	LogDriver string                           `json:"-"`
+	Labels    map[string]string                `json:"labels,omitempty"`
+	Stats     *utils.RingBuffer[ContainerStat] `json:"stats,omitempty"`
 }
 
 // ContainerStat represent stats instant for a container
@@ -48,12 +53,24 @@ const (
 )
 
 type LogEvent struct {
-	Message   any         `json:"m,omitempty"`
-	Timestamp int64       `json:"ts"`
-	Id        uint32      `json:"id,omitempty"`
-	Level     string      `json:"l,omitempty"`
-	Position  LogPosition `json:"p,omitempty"`
-	Stream    string      `json:"s,omitempty"`
+	Message   any            `json:"m,omitempty"`
+	Timestamp int64          `json:"ts"`
+	Id        uint32         `json:"id,omitempty"`
+	Level     string         `json:"l,omitempty"`
+	Position  LogPosition    `json:"p,omitempty"`
+	Stream    string         `json:"s,omitempty"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Seq       int64          `json:"seq,omitempty"`
+	Repeated  int            `json:"repeated,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	Time      string         `json:"time,omitempty"`
+	// Details are the attributes Docker attaches to the line, only set when details were requested
+	Details map[string]string `json:"details,omitempty"`
+	// StreamType is Stream as a StdType bit, only set when the raw stream type was requested
+	StreamType StdType `json:"streamType,omitempty"`
+	// Sampled is set on the first event sent after Skipped events were dropped to stay under a maximum rate
+	Sampled bool `json:"sampled,omitempty"`
+	Skipped int  `json:"skipped,omitempty"`
 }
 
 func (l *LogEvent) HasLevel() bool {
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"time"
+)
+
+// errRangeTooLarge stops a ranged download that outgrew the buffer since the range can't be served while streaming
+var errRangeTooLarge = errors.New("download exceeded buffer limit, range requests are not supported")
+
+// bufferedWriter holds a response in memory up to limit bytes so that Content-Length can be sent. Once the
+// limit is exceeded it writes out what it has and streams the rest without a length.
+type bufferedWriter struct {
+	w         http.ResponseWriter
+	r         *http.Request
+	buffer    bytes.Buffer
+	limit     int
+	streaming bool
+	failed    bool
+}
+
+func newBufferedWriter(w http.ResponseWriter, r *http.Request, limit int) *bufferedWriter {
+	return &bufferedWriter{w: w, r: r, limit: limit}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.failed {
+		return 0, errRangeTooLarge
+	}
+
+	if b.streaming {
+		return b.w.Write(p)
+	}
+
+	if b.buffer.Len()+len(p) > b.limit {
+		if b.r.Header.Get("Range") != "" {
+			b.failed = true
+			return 0, errRangeTooLarge
+		}
+		b.streaming = true
+		b.w.Header().Set("Warning", `199 - "download exceeded buffer limit, streaming without Content-Length"`)
+		if _, err := b.buffer.WriteTo(b.w); err != nil {
+			return 0, err
+		}
+		return b.w.Write(p)
+	}
+
+	return b.buffer.Write(p)
+}
+
+// Close writes the buffered response with its Content-Length, serving Range requests against the buffer with an
+// ETag of its content for If-Range. It does nothing if the writer already switched to streaming.
+func (b *bufferedWriter) Close() error {
+	if b.failed {
+		// the headers set for the download don't describe the error
+		b.w.Header().Del("Content-Encoding")
+		b.w.Header().Del("Content-Disposition")
+		b.w.Header().Del("Content-Type")
+		http.Error(b.w, errRangeTooLarge.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	if b.streaming {
+		return nil
+	}
+	h := fnv.New64a()
+	h.Write(b.buffer.Bytes())
+	b.w.Header().Set("ETag", fmt.Sprintf(`"%x"`, h.Sum64()))
+	http.ServeContent(b.w, b.r, "", time.Time{}, bytes.NewReader(b.buffer.Bytes()))
+	return nil
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type containerSummary struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// resolveContainers returns the name and state of each requested id. Unknown ids map to null.
+func (h *handler) resolveContainers(w http.ResponseWriter, r *http.Request) {
+	client := h.clientFromRequest(r)
+
+	summaries := make(map[string]*containerSummary)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+
+		if container, err := client.FindContainer(id); err == nil {
+			summaries[id] = &containerSummary{Name: container.Name, State: container.State}
+		} else {
+			log.Debugf("unable to resolve container %s: %v", id, err)
+			summaries[id] = nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		log.Errorf("json encoding error while resolving containers %v", err)
+	}
+}
+
+type containerMetadata struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Tty     bool   `json:"tty"`
+	Running bool   `json:"running"`
+}
+
+// containerMetadata lets clients know whether a container is Tty before opening a stream
+func (h *handler) containerMetadata(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(containerMetadata{
+		ID:      container.ID,
+		Name:    container.Name,
+		Tty:     container.Tty,
+		Running: container.State == "running",
+	}); err != nil {
+		log.Errorf("json encoding error while writing container metadata %v", err)
+	}
+}
+
+// streamLogsByName streams the logs of the container whose name starts with the name in the path, like docker
+// accepts name prefixes. An exact name always wins and more than one prefix match is a conflict.
+func (h *handler) streamLogsByName(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	containers, err := h.clientFromRequest(r).ListContainers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var candidates []docker.Container
+	for _, c := range containers {
+		if c.Name == name {
+			candidates = []docker.Container{c}
+			break
+		}
+		if strings.HasPrefix(c.Name, name) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		writeJSONError(w, http.StatusNotFound, errorResponse{Error: "no container matches " + name})
+		return
+	case 1:
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			names = append(names, c.Name)
+		}
+		writeJSONError(w, http.StatusConflict, errorResponse{Error: "more than one container matches " + name, Candidates: names})
+		return
+	}
+
+	chi.RouteContext(r.Context()).URLParams.Add("id", candidates[0].ID)
+	h.streamLogs(w, r)
+}
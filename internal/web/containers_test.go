@@ -0,0 +1,120 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_resolveContainers(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/resolve?ids=aaa,bbb", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "app", State: "running"}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{}, errors.New("not found"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"aaa":{"name":"app","state":"running"},"bbb":null}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_containerMetadata(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "123456").Return(docker.Container{ID: "123456", Name: "app", State: "running", Tty: true}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"id":"123456","name":"app","tty":true,"running":true}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_containerMetadata_not_found(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/unknown", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "unknown").Return(docker.Container{}, errors.New("not found"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.JSONEq(t, `{"error":"container not found","id":"unknown"}`, rr.Body.String())
+}
+
+func Test_handler_streamLogsByName(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/name/web/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "aaa", Name: "api"}, {ID: "bbb", Name: "web-1"}}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "web-1"}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "bbb", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `data: {"id":"bbb","name":"web-1","tty":false}`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogsByName_ambiguous(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/name/web/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "aaa", Name: "web-1"}, {ID: "bbb", Name: "web-2"}}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.JSONEq(t, `{"error":"more than one container matches web","candidates":["web-1","web-2"]}`, rr.Body.String())
+}
+
+func Test_handler_streamLogsByName_exact(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/name/web/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "aaa", Name: "web-1"}, {ID: "bbb", Name: "web"}}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "web"}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "bbb", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogsByName_not_found(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/name/web/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "aaa", Name: "api"}}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.JSONEq(t, `{"error":"no container matches web"}`, rr.Body.String())
+}
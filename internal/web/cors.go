@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+)
+
+// allowOrigin sets the CORS headers when the request comes from one of the configured origins
+func (h *handler) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	if len(h.config.CorsOrigins) == 0 {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !slices.Contains(h.config.CorsOrigins, origin) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	return true
+}
+
+func (h *handler) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.allowOrigin(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsPreflight answers OPTIONS requests, which browsers send without credentials
+func (h *handler) corsPreflight(w http.ResponseWriter, r *http.Request) {
+	if h.allowOrigin(w, r) && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", "600")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_cors_allowed_origin(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Origin", "https://app.example.com")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("INFO test\n")), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, CorsOrigins: []string{"https://app.example.com"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func Test_handler_cors_unknown_origin(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Origin", "https://evil.example.com")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("INFO test\n")), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, CorsOrigins: []string{"https://app.example.com"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func Test_handler_cors_preflight(t *testing.T) {
+	req, err := http.NewRequest("OPTIONS", "/api/hosts/localhost/containers/123456/logs/stream", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+
+	handler := createHandler(nil, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, CorsOrigins: []string{"https://app.example.com"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, HEAD, OPTIONS", rr.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "authorization", rr.Header().Get("Access-Control-Allow-Headers"))
+}
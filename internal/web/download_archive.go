@@ -0,0 +1,150 @@
+package web
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// downloadArchive zips the logs of several containers, one entry per container named with the download filename
+// template. Entries are gzipped unless format=txt. Containers that can't be found or read are listed in missing.txt.
+func (h *handler) downloadArchive(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "gzip" && format != "txt" {
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := timeRangeFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must be before to", http.StatusUnprocessableEntity)
+		return
+	}
+
+	client := h.clientFromRequest(r)
+	var containers []docker.Container
+	var missing []string
+	for _, id := range ids {
+		if container, err := client.FindContainer(id); err == nil {
+			containers = append(containers, container)
+		} else {
+			log.Debugf("skipping container %s from archive: %v", id, err)
+			missing = append(missing, id)
+		}
+	}
+
+	now := time.Now()
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=logs-%s.zip", now.Format("2006-01-02T15-04-05")))
+	w.Header().Set("Content-Type", "application/zip")
+
+	archive := zip.NewWriter(w)
+	defer func() {
+		if err := archive.Close(); err != nil {
+			log.Errorf("error while closing zip archive %v", err)
+		}
+	}()
+
+	// one container that can't be read doesn't fail the others, it is listed with the reason in missing.txt
+	var failed []string
+	names := make(map[string]bool)
+	for _, container := range containers {
+		name := strings.TrimSuffix(downloadFilename(h.config.DownloadFilename, container, now, "log"), ".log")
+		if names[name] {
+			name = name + "-" + container.ID
+		}
+		names[name] = true
+
+		if err := writeArchiveEntry(r, archive, client, container, name, format, from, to, stdTypes, now); err != nil {
+			if r.Context().Err() != nil {
+				return
+			}
+			log.Errorf("error while archiving logs of %s: %v", container.ID, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", container.ID, err))
+		}
+	}
+
+	if len(missing) > 0 || len(failed) > 0 {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: "missing.txt", Method: zip.Deflate, Modified: now})
+		if err != nil {
+			log.Errorf("error while writing missing.txt %v", err)
+			return
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(entry, "These containers could not be found and were not included:\n%s\n", strings.Join(missing, "\n"))
+		}
+		if len(failed) > 0 {
+			fmt.Fprintf(entry, "The logs of these containers could not be read and are missing or incomplete:\n%s\n", strings.Join(failed, "\n"))
+		}
+	}
+}
+
+func writeArchiveEntry(r *http.Request, archive *zip.Writer, client docker.Client, container docker.Container, name string, format string, from time.Time, to time.Time, stdTypes docker.StdType, modified time.Time) (err error) {
+	reader, err := client.ContainerLogsBetweenDates(r.Context(), container.ID, from, to, stdTypes, false)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := &zip.FileHeader{Name: name + ".log", Method: zip.Deflate, Modified: modified}
+	if format != "txt" {
+		// already compressed, deflating again would only cost time
+		header.Name, header.Method = name+".log.gz", zip.Store
+	}
+
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = entry
+	if format != "txt" {
+		zw := gzip.NewWriter(entry)
+		zw.Name = name + ".log"
+		zw.ModTime = modified
+		// the gzip footer is only written on close, an entry without it is as broken as one that failed to copy
+		defer func() {
+			if closeErr := zw.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		writer = zw
+	}
+
+	if container.Tty {
+		_, err = io.Copy(writer, reader)
+	} else {
+		_, err = stdcopy.StdCopy(writer, writer, reader)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// bundleManifest describes the logs of a bundle download so an export can be checked later
+type bundleManifest struct {
+	Container struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"container"`
+	// From is null when the export starts at the beginning of the logs
+	From       *time.Time `json:"from"`
+	To         time.Time  `json:"to"`
+	Lines      int        `json:"lines"`
+	File       string     `json:"file"`
+	ExportedAt time.Time  `json:"exportedAt"`
+	Version    string     `json:"version"`
+}
+
+// lineCounter counts the lines written through it
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.lines += bytes.Count(p[:n], []byte("\n"))
+	return n, err
+}
+
+// writeBundle writes a zip with the gzipped logs, a manifest.json describing them and a checksum.txt with the
+// SHA-256 of the gzipped logs in the format of sha256sum. The checksum and line count are taken while streaming.
+func writeBundle(w io.Writer, reader io.Reader, tty bool, stripAnsi bool, manifest bundleManifest) (err error) {
+	archive := zip.NewWriter(w)
+	// closed on every return so the entries written so far still make a valid archive
+	defer func() {
+		if closeErr := archive.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	// already compressed, deflating it again would only cost time
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: manifest.File, Method: zip.Store, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(entry, hash))
+	zw.Name = manifest.File
+	zw.ModTime = manifest.To
+	counter := &lineCounter{w: zw}
+	var logs io.Writer = counter
+	if stripAnsi {
+		logs = ansiStrippingWriter{logs}
+	}
+	if tty {
+		_, err = io.Copy(logs, reader)
+	} else {
+		_, err = stdcopy.StdCopy(logs, logs, reader)
+	}
+	// the logs read before an error are still closed into a valid gzip, the bundle then ends without a manifest
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest.Lines = counter.lines
+	entry, err = archive.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+
+	entry, err = archive.CreateHeader(&zip.FileHeader{Name: "checksum.txt", Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(entry, "%s  %s\n", hex.EncodeToString(hash.Sum(nil)), manifest.File)
+	return err
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"io"
+)
+
+const truncatedNote = "\n... [truncated by Dozzle]\n"
+
+var errDownloadLimit = errors.New("download size limit reached")
+
+// byteCounter counts what is written through it
+type byteCounter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedWriter stops accepting writes after max bytes went through it. It counts the bytes it is given, so above
+// a compressor the limit is on the logs before compression, which the compressed download stays under.
+type limitedWriter struct {
+	w       io.Writer
+	n       int64
+	max     int64
+	reached bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	remaining := l.max - l.n
+	if remaining <= 0 {
+		l.reached = true
+		return 0, errDownloadLimit
+	}
+	if int64(len(p)) > remaining {
+		l.reached = true
+		n, err := l.w.Write(p[:remaining])
+		l.n += int64(n)
+		if err == nil {
+			err = errDownloadLimit
+		}
+		return n, err
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
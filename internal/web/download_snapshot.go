@@ -0,0 +1,113 @@
+package web
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// downloadSnapshot zips the gzipped logs of every running container on the host. The archive is spooled to a temp
+// file so that it can be rejected with 413 when it grows past SnapshotMaxBytes before anything is sent.
+func (h *handler) downloadSnapshot(w http.ResponseWriter, r *http.Request) {
+	from, to, err := timeRangeFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must be before to", http.StatusUnprocessableEntity)
+		return
+	}
+
+	client := h.clientFromRequest(r)
+	list, err := client.ListContainers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var running []docker.Container
+	for _, c := range list {
+		if c.State == "running" {
+			running = append(running, c)
+		}
+	}
+
+	if h.config.SnapshotMaxContainers > 0 && len(running) > h.config.SnapshotMaxContainers {
+		http.Error(w, fmt.Sprintf("%d running containers is more than the snapshot limit of %d", len(running), h.config.SnapshotMaxContainers), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	spool, err := os.CreateTemp("", "dozzle-snapshot-*.zip")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	counter := &byteCounter{w: spool}
+	var writer io.Writer = counter
+	if h.config.SnapshotMaxBytes > 0 {
+		writer = &limitedWriter{w: counter, max: h.config.SnapshotMaxBytes}
+	}
+
+	now := time.Now()
+	archive := zip.NewWriter(writer)
+	names := make(map[string]bool)
+	for _, c := range running {
+		// the list doesn't know if a container is a tty, that needs the inspect done by FindContainer
+		container, err := client.FindContainer(c.ID)
+		if err != nil {
+			log.Debugf("skipping container %s from snapshot: %v", c.ID, err)
+			continue
+		}
+
+		name := unsafeFilenameChars.ReplaceAllString(container.Name, "_")
+		if names[name] {
+			name = name + "-" + container.ID
+		}
+		names[name] = true
+
+		if err = writeArchiveEntry(r, archive, client, container, name, "", from, to, docker.STDALL, now); err == nil {
+			continue
+		}
+		if errors.Is(err, errDownloadLimit) {
+			http.Error(w, fmt.Sprintf("snapshot is larger than the limit of %d bytes", h.config.SnapshotMaxBytes), http.StatusRequestEntityTooLarge)
+		} else {
+			log.Errorf("error while adding logs of %s to snapshot: %v", container.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := archive.Close(); err != nil {
+		if errors.Is(err, errDownloadLimit) {
+			http.Error(w, fmt.Sprintf("snapshot is larger than the limit of %d bytes", h.config.SnapshotMaxBytes), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=dozzle-snapshot-%s.zip", now.Format("2006-01-02T15-04-05")))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.FormatInt(counter.n, 10))
+	if _, err := io.Copy(w, spool); err != nil {
+		log.Warnf("snapshot download ended early: %v", err)
+	}
+}
@@ -1,16 +1,27 @@
 package web
 
 import (
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand"
 
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/amir20/dozzle/internal/docker"
+	"github.com/andybalholm/brotli"
 	"github.com/beme/abide"
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -25,7 +36,7 @@ func Test_handler_download_logs(t *testing.T) {
 	data := makeMessage("INFO Testing logs...", docker.STDOUT)
 
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
-	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
 
 	handler := createDefaultHandler(mockedClient)
 	rr := httptest.NewRecorder()
@@ -34,3 +45,1304 @@ func Test_handler_download_logs(t *testing.T) {
 	abide.AssertReader(t, t.Name(), reader)
 	mockedClient.AssertExpectations(t)
 }
+
+func Test_handler_download_logs_txt(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("INFO Testing logs...", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "test", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "text/plain; charset=UTF-8", rr.Header().Get("Content-Type"))
+	require.NotContains(t, rr.Header().Get("Content-Disposition"), ".gz")
+	abide.AssertReader(t, t.Name(), rr.Body)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_csv(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&stderr=1&format=csv", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing, with commas\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z ERROR \"quoted\" message\n", docker.STDERR)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "test", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Header().Get("Content-Disposition"), ".csv.gz")
+	reader, _ := gzip.NewReader(rr.Body)
+	abide.AssertReader(t, t.Name(), reader)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_invalid_compression(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&compression=0", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_default_std(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("ERROR Testing stderr logs...\n", docker.STDERR)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertReader(t, t.Name(), rr.Body)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_window(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&from=2020-05-13T18:00:00Z&to=2020-05-13T19:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	from, _ := time.Parse(time.RFC3339, "2020-05-13T18:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2020-05-13T19:00:00Z")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, from, to, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(makeMessage("INFO Testing logs...", docker.STDOUT))), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_since_event_id(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&from=2020-05-13T17:00:00Z&to=2020-05-13T19:00:00Z&sinceEventId=1589392800000-2", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	from, _ := time.Parse(time.RFC3339, "2020-05-13T18:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2020-05-13T19:00:00Z")
+
+	// the three events of the millisecond the archiver already has are skipped, the fourth one of it is new
+	var data []byte
+	for i, line := range []string{"first", "second", "third", "fourth"} {
+		data = append(data, makeMessage(fmt.Sprintf("2020-05-13T18:00:00.000%d00000Z INFO %s\n", i+1, line), docker.STDOUT)...)
+	}
+	data = append(data, makeMessage("2020-05-13T18:00:00.001000000Z INFO later\n", docker.STDOUT)...)
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.MatchedBy(from.Equal), to, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "2020-05-13T18:00:00.000400000Z INFO fourth\n2020-05-13T18:00:00.001000000Z INFO later\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_since_event_id_tty(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&sinceEventId=1589392800000", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	data := "2020-05-13T18:00:00.000100000Z INFO seen\n2020-05-13T18:00:00.000200000Z INFO new\n"
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.MatchedBy(time.UnixMilli(1589392800000).Equal), mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "2020-05-13T18:00:00.000200000Z INFO new\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_invalid_since_event_id(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&sinceEventId=yesterday", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "sinceEventId must be an event id\n", rr.Body.String())
+}
+
+func Test_handler_download_logs_relative_window(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&from=-1h", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	now := time.Now()
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.MatchedBy(func(from time.Time) bool {
+		return !from.Before(now.Add(-time.Hour)) && from.Before(now.Add(-time.Hour+time.Minute))
+	}), mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(makeMessage("INFO Testing logs...", docker.STDOUT))), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_malformed_from(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&from=yesterday", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid from")
+	mockedClient.AssertNotCalled(t, "ContainerLogsBetweenDates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_handler_download_logs_invalid_window(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&from=2020-05-13T19:00:00Z&to=2020-05-13T18:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_truncated_frame(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("INFO Testing logs...\n", docker.STDOUT)
+	truncated := makeMessage("INFO This frame is cut short", docker.STDOUT)
+	data = append(data, truncated[:len(truncated)-10]...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err, "response should be valid gzip")
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err, "gzip stream should not be corrupt")
+	require.Equal(t, "INFO Testing logs...\n", string(actual[:21]))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_ndjson(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&stderr=1&format=ndjson", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z {\"level\":\"error\",\"msg\":\"structured\"}\n", docker.STDERR)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "test", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Header().Get("Content-Disposition"), ".ndjson.gz")
+	reader, _ := gzip.NewReader(rr.Body)
+	abide.AssertReader(t, t.Name(), reader)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_buffered(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&buffer=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("INFO Testing logs...\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 1024})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "21", rr.Header().Get("Content-Length"))
+	require.Equal(t, "INFO Testing logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_buffer_exceeded(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&buffer=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := append(makeMessage("INFO Testing logs...\n", docker.STDOUT), makeMessage("INFO More logs...\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 25})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Empty(t, rr.Header().Get("Content-Length"))
+	require.NotEmpty(t, rr.Header().Get("Warning"))
+	require.Equal(t, "INFO Testing logs...\nINFO More logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_range(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&buffer=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Range", "bytes=5-")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("INFO Testing logs...\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 1024})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusPartialContent, rr.Code)
+	require.Equal(t, "bytes", rr.Header().Get("Accept-Ranges"))
+	require.Equal(t, "bytes 5-20/21", rr.Header().Get("Content-Range"))
+	require.NotEmpty(t, rr.Header().Get("ETag"))
+	require.Equal(t, "Testing logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_range_not_buffered(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Range", "bytes=5-")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_range_buffer_exceeded(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&buffer=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Range", "bytes=5-")
+
+	mockedClient := new(MockedClient)
+
+	data := append(makeMessage("INFO Testing logs...\n", docker.STDOUT), makeMessage("INFO More logs...\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 25})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_range_buffer_exceeded_headers(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&buffer=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Range", "bytes=5-")
+
+	mockedClient := new(MockedClient)
+
+	data := append(makeMessage("INFO Testing logs...\n", docker.STDOUT), makeMessage("INFO More logs...\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 25})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code)
+	require.Empty(t, rr.Header().Get("Content-Encoding"))
+	require.Empty(t, rr.Header().Get("Content-Disposition"))
+	require.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, errRangeTooLarge.Error()+"\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_annotate_streams(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&annotateStreams=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := append(makeMessage("INFO out\n", docker.STDOUT), makeMessage("ERROR err\n", docker.STDERR)...)
+	data = append(data, makeMessage("INFO split ", docker.STDOUT)...)
+	data = append(data, makeMessage("line\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "[stdout] INFO out\n[stderr] ERROR err\n[stdout] INFO split line\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_not_modified(t *testing.T) {
+	id := "123456"
+	finished := time.Date(2020, 5, 13, 18, 55, 37, 772853839, time.UTC)
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("If-Modified-Since", "Wed, 13 May 2020 18:55:37 GMT")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, State: "exited", Finished: finished}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Equal(t, "Wed, 13 May 2020 18:55:37 GMT", rr.Header().Get("Last-Modified"))
+	require.Empty(t, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_modified(t *testing.T) {
+	id := "123456"
+	finished := time.Date(2020, 5, 13, 18, 55, 37, 772853839, time.UTC)
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("If-Modified-Since", "Wed, 13 May 2020 18:00:00 GMT")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, State: "exited", Finished: finished, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "INFO Testing logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_running_not_cached(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("If-Modified-Since", "Wed, 13 May 2020 18:55:37 GMT")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, State: "running", Finished: time.Date(2020, 5, 13, 18, 0, 0, 0, time.UTC), Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("Last-Modified"))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_tail_bytes(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&tailBytes=20", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := append(makeMessage("INFO first line\n", docker.STDOUT), makeMessage("ERROR second\n", docker.STDERR)...)
+	data = append(data, makeMessage("INFO third\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 1024})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "INFO third\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_tail_bytes_too_large(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&tailBytes=2048", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 1024})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "tailBytes must be between 1 and 1024\n", rr.Body.String())
+}
+
+func Test_handler_download_logs_precompressed(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?recompress=false", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Add("Accept-Encoding", "gzip")
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("INFO already compressed\n"))
+	require.NoError(t, zw.Close())
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z "+compressed.String(), docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	require.Equal(t, compressed.Bytes(), rr.Body.Bytes(), "Expected the gzip stream to be passed through")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_recompress_plain(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?recompress=false", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO plain\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err, "Expected logs that aren't gzip to still be compressed")
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-13T18:55:37.772853839Z INFO plain\n", string(body))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_recompress_keeps_first_line(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?recompress=false", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := "2020-05-13T18:55:37.772853839Z INFO first\n2020-05-13T18:55:38.772853839Z INFO second\n"
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, data, string(body), "Expected the peeked first line to be kept")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_recompress_with_line_options(t *testing.T) {
+	id := "123456"
+	for _, option := range []string{"timestamps=true", "stripAnsi=true", "annotateStreams=true", "tailBytes=10", "lineNumbers=true"} {
+		req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?recompress=false&"+option, nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+		handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadBufferLimit: 1024})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, option)
+		mockedClient.AssertNotCalled(t, "ContainerLogsBetweenDates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func Test_handler_download_logs_recompress_unsupported_format(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=csv&recompress=false", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "recompress is only supported for gzip log downloads\n", rr.Body.String())
+}
+
+func Test_handler_download_logs_strict_std_types(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StrictStdTypes: true})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "stdout or stderr is required\n", rr.Body.String())
+}
+
+func Test_handler_download_logs_demux_stderr(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&demux=stderr", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("INFO out\n", docker.STDOUT), makeMessage("ERROR first\n", docker.STDERR)...)
+	data = append(data, makeMessage("INFO out again\n", docker.STDOUT)...)
+	data = append(data, makeMessage("ERROR second\n", docker.STDERR)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "ERROR first\nERROR second\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_demux_tty(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&demux=stderr", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "demux is not supported for containers with a TTY, which only have one stream\n", rr.Body.String())
+}
+
+func Test_handler_download_logs_demux_invalid(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=csv&demux=stderr", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "demux is only supported for plain log downloads\n", rr.Body.String())
+}
+
+func Test_handler_head_download_logs(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("HEAD", "/api/hosts/localhost/containers/"+id+"/logs/download?format=csv", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "test"}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/gzip", rr.Header().Get("Content-Type"))
+	require.Regexp(t, `^attachment; filename=test-.*\.csv\.gz$`, rr.Header().Get("Content-Disposition"))
+	require.Empty(t, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_head_download_logs_not_found(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("HEAD", "/api/hosts/localhost/containers/"+id+"/logs/download", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{}, errors.New("not found"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_strip_ansi(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?stdout=1&format=txt&stripAnsi=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("\x1b[32mINFO\x1b[0m Testing logs...\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "INFO Testing logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_retry(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	downloadRetryBackoff = time.Millisecond
+	defer func() { downloadRetryBackoff = 500 * time.Millisecond }()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), errors.New("connection reset")).Twice()
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil).Once()
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadRetries: 2})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "INFO Testing logs...\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_retries_exhausted(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	downloadRetryBackoff = time.Millisecond
+	defer func() { downloadRetryBackoff = 500 * time.Millisecond }()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), errors.New("connection reset")).Times(2)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadRetries: 1})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_log_driver_unsupported(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	downloadRetryBackoff = time.Millisecond
+	defer func() { downloadRetryBackoff = 500 * time.Millisecond }()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true, LogDriver: "awslogs"}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), errors.New(`configured logging driver does not support reading`)).Once()
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadRetries: 2})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), `"driver":"awslogs"`)
+	mockedClient.AssertNumberOfCalls(t, "ContainerLogsBetweenDates", 1)
+}
+
+func Test_handler_download_logs_concurrency_limit(t *testing.T) {
+	id := "123456"
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	started := make(chan struct{})
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).
+		Run(func(args mock.Arguments) { close(started) }).Return(reader, nil).Once()
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, MaxConcurrentDownloads: 1})
+
+	done := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	<-started
+
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	req, err = http.NewRequest("GET", "/api/hosts/localhost/logs/download?id="+id, nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code, "Expected archives to share the slots of downloads")
+
+	writer.Close()
+	<-done
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_zip(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=zip", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("INFO out\n", docker.STDOUT), makeMessage("ERROR err\n", docker.STDERR)...)
+	data = append(data, makeMessage("INFO out again\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	require.Regexp(t, `filename=web-.*\.zip$`, rr.Header().Get("Content-Disposition"))
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Equal(t, map[string]string{"stdout.log": "INFO out\nINFO out again\n", "stderr.log": "ERROR err\n"}, entries)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_zip_spool_limit(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=zip", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	zipSpoolLimit = 4
+	defer func() { zipSpoolLimit = 256 << 20 }()
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("INFO out\n", docker.STDOUT), makeMessage("ERROR err\n", docker.STDERR)...)
+	data = append(data, makeMessage("INFO out again\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Equal(t, map[string]string{"stdout.log": "INFO out\nINFO out again\n", "stderr.log": "ERRO" + truncatedNote}, entries)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_zip_tty(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=zip", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO tty\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Equal(t, map[string]string{"combined.log": "INFO tty\n"}, entries)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_bundle(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=bundle&from=2020-05-13T18:00:00Z&to=2020-05-13T19:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("INFO out\n", docker.STDOUT), makeMessage("ERROR err\n", docker.STDERR)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, Version: "v1.2.3"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	require.Regexp(t, `filename=web-.*\.zip$`, rr.Header().Get("Content-Disposition"))
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Len(t, entries, 3)
+
+	var manifest struct {
+		Container  struct{ ID, Name string }
+		From, To   string
+		Lines      int
+		File       string
+		ExportedAt string
+		Version    string
+	}
+	require.NoError(t, json.Unmarshal([]byte(entries["manifest.json"]), &manifest))
+	require.Equal(t, id, manifest.Container.ID)
+	require.Equal(t, "web", manifest.Container.Name)
+	require.Equal(t, "2020-05-13T18:00:00Z", manifest.From)
+	require.Equal(t, "2020-05-13T19:00:00Z", manifest.To)
+	require.Equal(t, 2, manifest.Lines)
+	require.Equal(t, "v1.2.3", manifest.Version)
+	require.Regexp(t, `^web-.*\.log\.gz$`, manifest.File)
+
+	compressed := entries[manifest.File]
+	reader, err := gzip.NewReader(strings.NewReader(compressed))
+	require.NoError(t, err)
+	logs, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "INFO out\nERROR err\n", string(logs))
+
+	checksum := sha256.Sum256([]byte(compressed))
+	require.Equal(t, hex.EncodeToString(checksum[:])+"  "+manifest.File+"\n", entries["checksum.txt"])
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_writeBundle_read_error(t *testing.T) {
+	var buf bytes.Buffer
+	reader := io.MultiReader(strings.NewReader("INFO out\n"), iotest.ErrReader(errors.New("daemon restarted")))
+	err := writeBundle(&buf, reader, true, false, bundleManifest{File: "web.log.gz"})
+	require.EqualError(t, err, "daemon restarted")
+
+	entries := readZip(t, buf.Bytes())
+	require.NotContains(t, entries, "manifest.json", "Expected a bundle that ended early to have no manifest")
+	gz, err := gzip.NewReader(strings.NewReader(entries["web.log.gz"]))
+	require.NoError(t, err)
+	logs, err := io.ReadAll(gz)
+	require.NoError(t, err, "Expected the logs read before the error to be a complete gzip")
+	require.Equal(t, "INFO out\n", string(logs))
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	entries := make(map[string]string)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		entries[file.Name] = string(content)
+	}
+	return entries
+}
+
+func Test_handler_download_logs_timestamps(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&timestamps=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z ERROR second\n  at line two\n", docker.STDERR)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "2020-05-13T18:55:37.772Z INFO first\n2020-05-13T18:56:37.772Z ERROR second\n  at line two\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_line_numbers(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&lineNumbers=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z ERROR second\n", docker.STDERR)...)
+	data = append(data, makeMessage("2020-05-13T18:57:37.772853839Z INFO third\n", docker.STDOUT)...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "000001 INFO first\n000001 ERROR second\n000002 INFO third\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_line_numbers_timestamps(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&stdout=1&lineNumbers=true&timestamps=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "000001 2020-05-13T18:55:37.772Z INFO first\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_brotli(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=br", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "application/x-brotli", rr.Header().Get("Content-Type"))
+	require.Regexp(t, `filename=web-.*\.log\.br$`, rr.Header().Get("Content-Disposition"))
+
+	body, err := io.ReadAll(brotli.NewReader(rr.Body))
+	require.NoError(t, err)
+	require.Equal(t, "INFO Testing logs...\n", string(body))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_brotli_accept_encoding(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=br&compression=11", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "br", rr.Header().Get("Content-Encoding"))
+
+	body, err := io.ReadAll(brotli.NewReader(rr.Body))
+	require.NoError(t, err)
+	require.Equal(t, "INFO Testing logs...\n", string(body))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_archive(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/download?ids=aaa,bbb,ccc&format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "web", Tty: true}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "db", Tty: false}, nil)
+	mockedClient.On("FindContainer", "ccc").Return(docker.Container{}, errors.New("not found"))
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO web\n")), nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "bbb", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(makeMessage("INFO db\n", docker.STDERR))), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadFilename: "{name}_{id}"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Equal(t, "INFO web\n", entries["web_aaa.log"])
+	require.Equal(t, "INFO db\n", entries["db_bbb.log"])
+	require.Contains(t, entries["missing.txt"], "ccc")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_archive_read_error(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/download?ids=aaa,bbb&format=txt", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "web", Tty: true}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "db", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), errors.New("connection reset"))
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "bbb", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO db\n")), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadFilename: "{name}"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Equal(t, "INFO db\n", entries["db.log"])
+	require.NotContains(t, entries, "web.log")
+	require.Contains(t, entries["missing.txt"], "aaa: connection reset")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_archive_malformed_from(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/download?ids=aaa&from=yesterday", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func Test_handler_download_archive_invalid_window(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/download?ids=aaa&from=2020-05-13T19:00:00Z&to=2020-05-13T18:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	mockedClient.AssertNotCalled(t, "FindContainer", mock.Anything)
+}
+
+func Test_writeArchiveEntry_close_error(t *testing.T) {
+	// logs that don't compress stay in the gzip writer until it is closed, so only closing it reaches the broken writer
+	logs := make([]byte, 8*1024)
+	rand.New(rand.NewSource(1)).Read(logs)
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(logs)), nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	archive := zip.NewWriter(failingWriter{httptest.NewRecorder()})
+	err := writeArchiveEntry(req, archive, mockedClient, docker.Container{ID: "aaa", Tty: true}, "web", "gzip", time.Time{}, time.Now(), docker.STDALL, time.Now())
+	require.Error(t, err, "Expected the error of closing the gzip writer")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_archive_gzip(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/download?ids=aaa", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO web\n")), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DownloadFilename: "{name}"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Len(t, entries, 1)
+	reader, err := gzip.NewReader(strings.NewReader(entries["web.log.gz"]))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "INFO web\n", string(content))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_max_bytes(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?format=txt&maxBytes=10", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("0123456789ABCDEF\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "0123456789\n... [truncated by Dozzle]\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_logs_max_bytes_archive(t *testing.T) {
+	id := "123456"
+	for _, format := range []string{"zip", "bundle"} {
+		req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?maxBytes=10&format="+format, nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+		handler := createDefaultHandler(mockedClient)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, format)
+		require.Equal(t, "maxBytes is not supported for "+format+" downloads\n", rr.Body.String())
+	}
+}
+
+func Test_handler_download_logs_max_bytes_gzip(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/download?maxBytes=64", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	// random lines don't compress well so they go over the limit quickly
+	var data bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&data, "%x\n", time.Now().UnixNano()*int64(i+1)*7919)
+	}
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data.Bytes())), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "gzip stream should be closed cleanly")
+	require.True(t, strings.HasSuffix(string(content), "... [truncated by Dozzle]\n"))
+	require.Equal(t, 64+len(truncatedNote), len(content), "Expected the limit to be on the logs before compression")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_snapshot(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/snapshot", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{
+		{ID: "aaa", Name: "web", State: "running"},
+		{ID: "bbb", Name: "db", State: "exited"},
+	}, nil)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("INFO web\n")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Regexp(t, `^attachment; filename=dozzle-snapshot-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.zip$`, rr.Header().Get("Content-Disposition"))
+	require.Equal(t, fmt.Sprint(rr.Body.Len()), rr.Header().Get("Content-Length"))
+
+	entries := readZip(t, rr.Body.Bytes())
+	require.Len(t, entries, 1)
+	reader, err := gzip.NewReader(strings.NewReader(entries["web.log.gz"]))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "INFO web\n", string(content))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_snapshot_malformed_from(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/snapshot?from=yesterday", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func Test_handler_download_snapshot_invalid_window(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/snapshot?from=2020-05-13T19:00:00Z&to=2020-05-13T18:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	mockedClient.AssertNotCalled(t, "ListContainers")
+}
+
+func Test_handler_download_snapshot_too_many_containers(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/snapshot", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{
+		{ID: "aaa", Name: "web", State: "running"},
+		{ID: "bbb", Name: "db", State: "running"},
+	}, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, SnapshotMaxContainers: 1})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_download_snapshot_too_large(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/snapshot", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "aaa", Name: "web", State: "running"}}, nil)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, "aaa", mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(strings.NewReader(strings.Repeat("INFO web\n", 100))), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, SnapshotMaxBytes: 64})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Empty(t, rr.Header().Get("Content-Disposition"))
+	mockedClient.AssertExpectations(t)
+}
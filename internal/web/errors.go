@@ -0,0 +1,39 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+	ID    string `json:"id,omitempty"`
+	// Candidates lists the names that matched when a lookup was ambiguous
+	Candidates []string `json:"candidates,omitempty"`
+	// Driver is the logging driver of a container whose logs can't be read
+	Driver string `json:"driver,omitempty"`
+}
+
+// writeJSONError replies with a JSON error object and the given status code. It is the JSON counterpart of http.Error.
+func writeJSONError(w http.ResponseWriter, status int, response errorResponse) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("error while encoding error response: %v", err)
+	}
+}
+
+func writeLogDriverUnsupported(w http.ResponseWriter, container docker.Container) {
+	writeJSONError(w, http.StatusUnprocessableEntity, errorResponse{Error: "logging driver does not support reading logs", ID: container.ID, Driver: container.LogDriver})
+}
+
+func writeContainerNotFound(w http.ResponseWriter, status int, id string, err error) {
+	log.Debugf("unable to find container %s: %v", id, err)
+	writeJSONError(w, status, errorResponse{Error: "container not found", ID: id})
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// eventFieldNames maps the names accepted by ?fields= to the JSON keys of a streamed event. Every key can be asked
+// for by itself or by the name of its Go field with a lower case first letter, like message for m. eventFields has
+// where each key is found in a highlightedLogEvent.
+var eventFieldNames, eventFields = jsonFields(reflect.TypeOf(highlightedLogEvent{}), nil)
+
+// jsonField is the struct field a JSON key is encoded from
+type jsonField struct {
+	index     []int
+	omitEmpty bool
+}
+
+// jsonFields reads the JSON keys of a struct and the structs embedded in it from their json tags
+func jsonFields(t reflect.Type, index []int) (map[string]string, map[string]jsonField) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	names := make(map[string]string)
+	fields := make(map[string]jsonField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			embeddedNames, embeddedFields := jsonFields(field.Type, fieldIndex)
+			for name, key := range embeddedNames {
+				names[name] = key
+			}
+			for key, f := range embeddedFields {
+				fields[key] = f
+			}
+			continue
+		}
+		key, options, _ := strings.Cut(tag, ",")
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		names[key] = key
+		runes := []rune(field.Name)
+		runes[0] = unicode.ToLower(runes[0])
+		names[string(runes)] = key
+		fields[key] = jsonField{index: fieldIndex, omitEmpty: strings.Contains(options, "omitempty")}
+	}
+	return names, fields
+}
+
+// eventFieldsFromRequest returns the JSON keys listed in ?fields=, or nil to send the whole event. Unknown names are ignored.
+func eventFieldsFromRequest(r *http.Request) map[string]bool {
+	var keep map[string]bool
+	for _, name := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if key, ok := eventFieldNames[strings.TrimSpace(name)]; ok {
+			if keep == nil {
+				keep = make(map[string]bool)
+			}
+			keep[key] = true
+		}
+	}
+	return keep
+}
+
+// filterEventFields takes the kept keys of an event into a map that is encoded in one pass. Empty values are left
+// out just like the struct tags do.
+func filterEventFields(event highlightedLogEvent, keep map[string]bool) map[string]any {
+	value := reflect.ValueOf(event)
+	fields := make(map[string]any, len(keep))
+	for key := range keep {
+		field := value.FieldByIndex(eventFields[key].index)
+		if eventFields[key].omitEmpty && isEmptyValue(field) {
+			continue
+		}
+		fields[key] = field.Interface()
+	}
+	return fields
+}
+
+// isEmptyValue reports whether omitempty leaves v out, which is the same test encoding/json uses
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"sync"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+// eventQueue sits between an EventGenerator and a slow client. It holds at most size events and drops the
+// oldest ones when it is full, so a client that doesn't keep up costs bounded memory. The events are kept in a
+// ring so dropping one doesn't move the others.
+type eventQueue struct {
+	mu      sync.Mutex
+	ring    []*docker.LogEvent
+	head    int
+	count   int
+	taken   []*docker.LogEvent
+	dropped int
+	closed  bool
+	ready   chan struct{}
+}
+
+// defaultStreamQueueSize is used when no queue size is configured
+const defaultStreamQueueSize = 1000
+
+func newEventQueue(size int) *eventQueue {
+	if size <= 0 {
+		size = defaultStreamQueueSize
+	}
+	return &eventQueue{
+		ring:  make([]*docker.LogEvent, size),
+		taken: make([]*docker.LogEvent, 0, size),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// pump moves events from the generator to the queue until the generator is done
+func (q *eventQueue) pump(g *docker.EventGenerator) {
+	for event := range g.Events {
+		q.push(event)
+	}
+	q.close()
+}
+
+func (q *eventQueue) push(event *docker.LogEvent) {
+	q.mu.Lock()
+	if q.count == len(q.ring) {
+		// the oldest event is overwritten
+		q.ring[q.head] = event
+		q.head = (q.head + 1) % len(q.ring)
+		q.dropped++
+	} else {
+		q.ring[(q.head+q.count)%len(q.ring)] = event
+		q.count++
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns everything queued, how many events were dropped since the last take and whether the queue is done.
+// The events are only valid until the next take, which reuses the slice.
+func (q *eventQueue) take() ([]*docker.LogEvent, int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	events := q.taken[:0]
+	for i := 0; i < q.count; i++ {
+		index := (q.head + i) % len(q.ring)
+		events = append(events, q.ring[index])
+		q.ring[index] = nil
+	}
+	dropped := q.dropped
+	q.taken, q.head, q.count, q.dropped = events, 0, 0, 0
+	return events, dropped, q.closed
+}
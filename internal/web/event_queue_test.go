@@ -0,0 +1,48 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_eventQueue_drops_oldest(t *testing.T) {
+	q := newEventQueue(2)
+	for i := int64(1); i <= 5; i++ {
+		q.push(&docker.LogEvent{Timestamp: i})
+	}
+
+	events, dropped, closed := q.take()
+	require.Len(t, events, 2)
+	require.Equal(t, int64(4), events[0].Timestamp)
+	require.Equal(t, int64(5), events[1].Timestamp)
+	require.Equal(t, 3, dropped)
+	require.False(t, closed)
+
+	q.close()
+	events, dropped, closed = q.take()
+	require.Empty(t, events)
+	require.Zero(t, dropped)
+	require.True(t, closed)
+}
+
+func Test_eventQueue_wraps_around(t *testing.T) {
+	q := newEventQueue(3)
+	for i := int64(1); i <= 2; i++ {
+		q.push(&docker.LogEvent{Timestamp: i})
+	}
+	events, _, _ := q.take()
+	require.Len(t, events, 2)
+
+	// the ring fills up and wraps around, overwriting the oldest events
+	for i := int64(3); i <= 7; i++ {
+		q.push(&docker.LogEvent{Timestamp: i})
+	}
+	events, dropped, _ := q.take()
+	require.Len(t, events, 3)
+	require.Equal(t, int64(5), events[0].Timestamp)
+	require.Equal(t, int64(6), events[1].Timestamp)
+	require.Equal(t, int64(7), events[2].Timestamp)
+	require.Equal(t, 2, dropped)
+}
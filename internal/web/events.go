@@ -110,6 +110,10 @@ func (h *handler) streamEvents(w http.ResponseWriter, r *http.Request) {
 				}
 				f.Flush()
 			}
+		case <-h.shutdown:
+			writeServerShutdown(w)
+			f.Flush()
+			return
 		case <-ctx.Done():
 			log.Debugf("context done, closing event stream")
 			return
@@ -65,3 +65,25 @@ func Test_handler_streamEvents_happy(t *testing.T) {
 	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
 	mockedClient.AssertExpectations(t)
 }
+
+func Test_handler_streamEvents_server_shutdown(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/events/stream", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ListContainers").Return([]docker.Container{}, nil)
+	mockedClient.On("Events", mock.Anything, mock.AnythingOfType("chan<- docker.ContainerEvent")).Return(nil)
+	mockedClient.On("Host").Return(&docker.Host{ID: "localhost"})
+
+	server := CreateServer(map[string]docker.Client{"localhost": mockedClient}, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}})
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.Handler.ServeHTTP(rr, req)
+		close(done)
+	}()
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	<-done
+	require.Contains(t, rr.Body.String(), "event: server-shutdown\ndata: server is shutting down\n\n")
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+// DefaultFilenameTemplate is the name of downloads before the extension is added
+const DefaultFilenameTemplate = "{name}-{date}"
+
+var filenamePlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+var filenamePlaceholders = map[string]func(docker.Container, time.Time) string{
+	"{name}": func(c docker.Container, _ time.Time) string { return c.Name },
+	"{id}":   func(c docker.Container, _ time.Time) string { return c.ID },
+	"{date}": func(_ docker.Container, now time.Time) string { return now.Format("2006-01-02T15-04-05") },
+}
+
+// ValidateFilenameTemplate checks that a download filename template only uses known placeholders
+func ValidateFilenameTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("filename template cannot be empty")
+	}
+	for _, placeholder := range filenamePlaceholder.FindAllString(template, -1) {
+		if _, ok := filenamePlaceholders[placeholder]; !ok {
+			return fmt.Errorf("unknown placeholder %s in filename template, expected one of {name}, {id} or {date}", placeholder)
+		}
+	}
+	return nil
+}
+
+// downloadFilename fills in the template and replaces anything that isn't safe in a Content-Disposition header
+func downloadFilename(template string, container docker.Container, now time.Time, extension string) string {
+	if template == "" {
+		template = DefaultFilenameTemplate
+	}
+	name := filenamePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := filenamePlaceholders[placeholder]; ok {
+			return value(container, now)
+		}
+		return placeholder
+	})
+	return unsafeFilenameChars.ReplaceAllString(name, "_") + "." + extension
+}
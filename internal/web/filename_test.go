@@ -0,0 +1,34 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	require.NoError(t, ValidateFilenameTemplate(DefaultFilenameTemplate))
+	require.NoError(t, ValidateFilenameTemplate("archive_{id}"))
+	require.Error(t, ValidateFilenameTemplate(""))
+	require.Error(t, ValidateFilenameTemplate("{name}-{host}"))
+}
+
+func Test_downloadFilename(t *testing.T) {
+	container := docker.Container{ID: "123456", Name: "my app"}
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"", "my_app-2024-01-01T10-30-00.log"},
+		{"{name}_{id}", "my_app_123456.log"},
+		{"logs\r\nSet-Cookie: a=b", "logs_Set-Cookie_a_b.log"},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, downloadFilename(test.template, container, now, "log"), test.template)
+	}
+}
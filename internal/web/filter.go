@@ -0,0 +1,171 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+)
+
+type logFilter struct {
+	re                  *regexp.Regexp
+	contains            string
+	caseInsensitive     bool
+	levels              map[string]bool
+	includeUnknownLevel bool
+	// fields are the values that keys of structured messages must have, from field.<key>=<value>
+	fields map[string]string
+}
+
+// filterFromRequest parses the filtering query parameters. A nil filter matches everything.
+func filterFromRequest(r *http.Request) (*logFilter, error) {
+	query := r.URL.Query()
+	pattern := query.Get("filter")
+	contains := query.Get("contains")
+	levels := query.Get("levels")
+
+	var fields map[string]string
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "field."); ok && name != "" {
+			if fields == nil {
+				fields = make(map[string]string)
+			}
+			fields[name] = values[0]
+		}
+	}
+
+	if pattern == "" && contains == "" && levels == "" && fields == nil {
+		return nil, nil
+	}
+
+	filter := &logFilter{fields: fields}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		filter.re = re
+	}
+
+	if contains != "" {
+		filter.caseInsensitive = query.Get("ci") == "true"
+		if filter.caseInsensitive {
+			contains = strings.ToLower(contains)
+		}
+		filter.contains = contains
+	}
+
+	if levels != "" {
+		filter.levels = make(map[string]bool)
+		for _, level := range strings.Split(levels, ",") {
+			if level = strings.ToLower(strings.TrimSpace(level)); level != "" {
+				filter.levels[level] = true
+			}
+		}
+		filter.includeUnknownLevel = query.Get("includeUnknownLevel") == "true"
+	}
+
+	return filter, nil
+}
+
+func (f *logFilter) matches(event *docker.LogEvent) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.levels != nil {
+		if event.HasLevel() {
+			if !f.levels[strings.ToLower(event.Level)] {
+				return false
+			}
+		} else if !f.includeUnknownLevel {
+			return false
+		}
+	}
+
+	for key, value := range f.fields {
+		if field, ok := structuredField(event, key); !ok || field != value {
+			return false
+		}
+	}
+
+	if f.re == nil && f.contains == "" {
+		return true
+	}
+
+	text := messageText(event)
+	if f.contains != "" {
+		if f.caseInsensitive {
+			if !strings.Contains(strings.ToLower(text), f.contains) {
+				return false
+			}
+		} else if !strings.Contains(text, f.contains) {
+			return false
+		}
+	}
+
+	if f.re != nil && !f.re.MatchString(text) {
+		return false
+	}
+
+	return true
+}
+
+// highlightedLogEvent is a log event annotated with the byte ranges of the message that matched the filter
+type highlightedLogEvent struct {
+	*docker.LogEvent
+	Matches [][]int `json:"matches,omitempty"`
+}
+
+// highlight returns the [start,end] offsets of filter matches in the message text. It returns nil when there is no regex filter.
+func (f *logFilter) highlight(event *docker.LogEvent) [][]int {
+	if f == nil || f.re == nil {
+		return nil
+	}
+	return f.re.FindAllStringIndex(messageText(event), -1)
+}
+
+// structuredField returns a value of a JSON or logfmt message as text. Values that aren't strings are compared
+// in their JSON form, so numbers and booleans are written as they are in the log.
+func structuredField(event *docker.LogEvent, key string) (string, bool) {
+	var value any
+	var ok bool
+	switch message := event.Message.(type) {
+	case map[string]string:
+		value, ok = message[key]
+	case map[string]any:
+		value, ok = message[key]
+	default:
+		// parseJson moves the fields of JSON messages out of the message
+		value, ok = event.Fields[key]
+	}
+	if !ok {
+		return "", false
+	}
+	if text, isString := value.(string); isString {
+		return text, true
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(buf), true
+}
+
+// messageText returns the decoded message of an event as text. Structured messages are matched against their JSON form.
+func messageText(event *docker.LogEvent) string {
+	switch message := event.Message.(type) {
+	case string:
+		return message
+	case nil:
+		return ""
+	default:
+		if buf, err := json.Marshal(message); err == nil {
+			return string(buf)
+		}
+		return fmt.Sprint(message)
+	}
+}
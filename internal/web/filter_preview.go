@@ -0,0 +1,76 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type filterPreview struct {
+	Scanned int                   `json:"scanned"`
+	Matches []highlightedLogEvent `json:"matches"`
+}
+
+// previewFilter runs a filter against the last tail lines and returns the ones that match without opening a stream
+func (h *handler) previewFilter(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter == nil {
+		http.Error(w, "filter, contains or levels is required", http.StatusBadRequest)
+		return
+	}
+
+	tail := docker.DefaultTail
+	if r.URL.Query().Has("tail") {
+		tail, err = strconv.Atoi(r.URL.Query().Get("tail"))
+		if err != nil || tail < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	reader, err := h.clientFromRequest(r).ContainerLogsTail(r.Context(), container.ID, tail, stdTypes, false)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	preview := filterPreview{Matches: []highlightedLogEvent{}}
+	if err == nil {
+		g := h.newEventGenerator(reader, container.Tty)
+		for event := range g.Events {
+			preview.Scanned++
+			if filter.matches(event) {
+				preview.Matches = append(preview.Matches, highlightedLogEvent{LogEvent: event, Matches: filter.highlight(event)})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		log.Errorf("json encoding error while previewing filter %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_previewFilter(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/filter-preview?stdout=1&tail=3&filter=err(or)?", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO all good\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z ERROR an error\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsTail", mock.Anything, id, 3, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"scanned":2,"matches":[{"m":"ERROR an error","ts":1589396197772,"id":1060183059,"l":"error","s":"stdout","matches":[[9,14]]}]}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_previewFilter_invalid_regex(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/filter-preview?filter=(", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid filter: error parsing regexp: missing closing ): `(`")
+}
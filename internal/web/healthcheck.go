@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,3 +24,30 @@ func (h *handler) healthcheck(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "OK API Version %v", ping.APIVersion)
 	}
 }
+
+type healthzResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Host   string `json:"host,omitempty"`
+}
+
+// healthz pings every Docker host and fails with 503 if any of them is unreachable
+func (h *handler) healthz(w http.ResponseWriter, r *http.Request) {
+	response := healthzResponse{Status: "ok"}
+	status := http.StatusOK
+	for host, client := range h.clients {
+		if _, err := client.Ping(r.Context()); err != nil {
+			log.Warnf("healthz failed to ping %s: %v", host, err)
+			response = healthzResponse{Status: "error", Error: err.Error(), Host: host}
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("json encoding error while writing healthz %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_healthz(t *testing.T) {
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("Ping", mock.Anything).Return(types.Ping{APIVersion: "1.43"}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_healthz_unreachable(t *testing.T) {
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("Ping", mock.Anything).Return(types.Ping{}, errors.New("cannot connect to the Docker daemon"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.JSONEq(t, `{"status":"error","error":"cannot connect to the Docker daemon","host":"localhost"}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
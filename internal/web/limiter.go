@@ -0,0 +1,81 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/amir20/dozzle/internal/auth"
+)
+
+// limitConcurrency allows at most limit requests through next at once and rejects the rest with 429. All the routes
+// the middleware is used on share the same slots.
+func limitConcurrency(limit int) func(http.Handler) http.Handler {
+	var slots chan struct{}
+	if limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, "too many concurrent downloads", http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+// limitPerClient allows every client at most limit requests through next at once and rejects the rest with 429.
+// The count is released when next returns, which streams do as soon as the client goes away. All the routes the
+// middleware is used on share the same counts.
+func limitPerClient(limit int) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	open := make(map[string]int)
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientIdentity(r)
+			mu.Lock()
+			if open[client] >= limit {
+				mu.Unlock()
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, "too many concurrent streams", http.StatusTooManyRequests)
+				return
+			}
+			open[client]++
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				if open[client]--; open[client] == 0 {
+					delete(open, client)
+				}
+				mu.Unlock()
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIdentity is the user of a request when there is one, otherwise the address it came from
+func clientIdentity(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return "user:" + user.Username
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
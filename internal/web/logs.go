@@ -1,8 +1,14 @@
 package web
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
 	"strings"
 
 	"github.com/goccy/go-json"
@@ -10,11 +16,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"runtime"
+	"sort"
+	"strconv"
 
 	"time"
+	"unicode/utf8"
 
 	"github.com/amir20/dozzle/internal/docker"
+	"github.com/andybalholm/brotli"
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/dustin/go-humanize"
 	"github.com/go-chi/chi/v5"
@@ -25,112 +37,1251 @@ import (
 func (h *handler) downloadLogs(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusBadRequest, id, err)
+		return
+	}
+
+	now := time.Now()
+
+	// demux=stderr reads both streams from Docker and only keeps the frames of stderr, demux=stdout the ones of
+	// stdout. The kept frames stay in the order they were written in.
+	var demux docker.StdType
+	if r.URL.Query().Has("demux") {
+		if demux = docker.ParseStdType(r.URL.Query().Get("demux")); demux != docker.STDOUT && demux != docker.STDERR {
+			http.Error(w, "demux must be stdout or stderr", http.StatusBadRequest)
+			return
+		}
+		if container.Tty {
+			http.Error(w, "demux is not supported for containers with a TTY, which only have one stream", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if demux != 0 {
+		stdTypes = docker.STDALL
+	}
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	downloadFormat, ok := downloadFormats[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+	filename := downloadFilename(h.config.DownloadFilename, container, now, downloadFormat.extension)
+
+	from, to, err := timeRangeFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	// sinceEventId resumes after the id of the last event an archiver saw, which is its timestamp in milliseconds
+	// with an optional -sequence. The later of it and from is used. Like a resumed stream, the download starts at
+	// that millisecond and skips the events of it the archiver already has.
+	var resumeTimestamp int64
+	skip := 0
+	if r.URL.Query().Has("sinceEventId") {
+		if _, resumeTimestamp, skip = resumeFromEventId(r.URL.Query().Get("sinceEventId")); skip == 0 {
+			http.Error(w, "sinceEventId must be an event id", http.StatusBadRequest)
+			return
+		}
+		if since := time.UnixMilli(resumeTimestamp); !since.Before(from) {
+			from = since
+		} else {
+			skip = 0
+		}
+	}
+	if from.After(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	// compression selects the gzip level from 1 (fastest) to 9 (smallest). 0 (no compression) isn't
+	// allowed here, format=txt should be used for uncompressed downloads instead. Brotli goes from 0 to 11.
+	level, minLevel, maxLevel := gzip.DefaultCompression, gzip.BestSpeed, gzip.BestCompression
+	if downloadFormat.encoding == "br" {
+		level, minLevel, maxLevel = brotli.DefaultCompression, brotli.BestSpeed, brotli.BestCompression
+	}
+	if r.URL.Query().Has("compression") {
+		level, err = strconv.Atoi(r.URL.Query().Get("compression"))
+		if err != nil || level < minLevel || level > maxLevel {
+			http.Error(w, fmt.Sprintf("compression must be between %d and %d", minLevel, maxLevel), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// maxBytes caps the size of the logs in the download, falling back to the server wide default. Archives can't be
+	// cut short without breaking them, so they are never capped.
+	maxBytes := h.config.DownloadMaxBytes
+	if r.URL.Query().Has("maxBytes") {
+		if maxBytes, err = strconv.ParseInt(r.URL.Query().Get("maxBytes"), 10, 64); err != nil || maxBytes <= 0 {
+			http.Error(w, "maxBytes must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if !downloadFormat.supportsMaxBytes {
+			http.Error(w, fmt.Sprintf("maxBytes is not supported for %s downloads", format), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if !downloadFormat.supportsMaxBytes {
+		maxBytes = 0
+	}
+
+	// timestamps=true prefixes every message with its time like docker logs -t
+	timestamps := r.URL.Query().Get("timestamps") == "true"
+
+	// stripAnsi removes color codes, without it the raw bytes are kept for terminal replays
+	stripAnsi := r.URL.Query().Get("stripAnsi") == "true"
+
+	// annotateStreams=true marks which stream every line came from since both end up in the same file
+	annotateStreams := r.URL.Query().Get("annotateStreams") == "true"
+
+	// lineNumbers=true prefixes every line with its number in its stream to point at lines in bug reports
+	lineNumbers := r.URL.Query().Get("lineNumbers") == "true"
+
+	// tailBytes keeps only about the last N bytes of the logs. Docker can only tail by lines, so everything is read
+	// and the end is kept in memory, which is why it is capped by the buffer limit.
+	tailBytes := 0
+	if r.URL.Query().Has("tailBytes") {
+		if tailBytes, err = strconv.Atoi(r.URL.Query().Get("tailBytes")); err != nil || tailBytes <= 0 || tailBytes > h.config.DownloadBufferLimit {
+			http.Error(w, fmt.Sprintf("tailBytes must be between 1 and %d", h.config.DownloadBufferLimit), http.StatusBadRequest)
+			return
+		}
+		if !downloadFormat.supportsTail || timestamps || lineNumbers {
+			http.Error(w, "tailBytes is only supported for plain log downloads", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// recompress=false sends logs that are already gzip as they are instead of compressing them a second time
+	recompress := r.URL.Query().Get("recompress") != "false"
+	if !recompress && !downloadFormat.supportsRecompress {
+		http.Error(w, "recompress is only supported for gzip log downloads", http.StatusBadRequest)
+		return
+	}
+	// a stream that is already gzip is passed through, so nothing can be changed in its lines
+	if !recompress && (timestamps || stripAnsi || annotateStreams || tailBytes > 0 || lineNumbers) {
+		http.Error(w, "recompress=false can't be combined with timestamps, stripAnsi, annotateStreams, tailBytes or lineNumbers", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if lineNumbers && !downloadFormat.supportsLineNumbers {
+		http.Error(w, "lineNumbers is only supported for plain log downloads", http.StatusBadRequest)
+		return
+	}
+
+	if demux != 0 && (!downloadFormat.supportsDemux || timestamps || lineNumbers || !recompress) {
+		http.Error(w, "demux is only supported for plain log downloads", http.StatusBadRequest)
+		return
+	}
+
+	// the logs of a container that has exited can't change anymore, so they are cacheable by its finish time.
+	// Running, paused and restarting containers may still write logs and are never cached.
+	if (container.State == "exited" || container.State == "dead") && !container.Finished.IsZero() {
+		lastModified := container.Finished.UTC().Truncate(time.Second)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// buffer=true sends small downloads with a Content-Length so browsers can show progress and resume them
+	// with a Range request. Streamed downloads have no length to take a range of.
+	buffered := r.URL.Query().Get("buffer") == "true"
+	if r.Header.Get("Range") != "" && !buffered {
+		http.Error(w, "range requests need buffer=true", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	reader, err := h.downloadReader(r, id, from, to, stdTypes)
+	if docker.IsLogDriverUnsupported(err) {
+		writeLogDriverUnsupported(w, container)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var logs io.Reader = reader
+	if skip > 0 {
+		logs = skipResumedEvents(reader, container.Tty, resumeTimestamp, skip)
+	}
+	var peeked *bufio.Reader
+	precompressed := false
+	if !recompress {
+		peeked = bufio.NewReader(logs)
+		precompressed = isPrecompressed(peeked, container.Tty)
+		logs = peeked
+	}
+
+	var writer io.Writer = w
+
+	if buffered {
+		bw := newBufferedWriter(w, r, h.config.DownloadBufferLimit)
+		defer func() {
+			if err := bw.Close(); err != nil {
+				log.Errorf("error while writing buffered download %v", err)
+			}
+		}()
+		writer = bw
+	}
+
+	setDownloadHeaders(w, r, format, filename)
+
+	switch downloadFormat.encoding {
+	case "br":
+		bw := brotli.NewWriterLevel(writer, level)
+		// always close so the final block is written even if reading the logs failed midway
+		defer func() {
+			if err := bw.Close(); err != nil {
+				log.Errorf("error while closing brotli writer %v", err)
+			}
+		}()
+		writer = bw
+	case "gzip":
+		if precompressed {
+			break
+		}
+		zw, _ := gzip.NewWriterLevel(writer, level)
+		// always close so the gzip trailer is written even if reading the logs failed midway
+		defer func() {
+			if err := zw.Close(); err != nil {
+				log.Errorf("error while closing gzip writer %v", err)
+			}
+		}()
+		zw.Name = filename
+		zw.Comment = "Logs generated by Dozzle"
+		// the end of the range rather than now keeps the bytes the same when a download with a fixed to is resumed
+		zw.ModTime = to
+		writer = zw
+	}
+
+	if maxBytes > 0 {
+		encoded := writer
+		limited := &limitedWriter{w: encoded, max: maxBytes}
+		// runs before the compressed writers are closed so the note ends up inside the stream
+		defer func() {
+			// text after the end of a precompressed stream would only corrupt it
+			if limited.reached && !precompressed {
+				log.Debugf("download of %s truncated at %d bytes", container.ID, maxBytes)
+				fmt.Fprint(encoded, truncatedNote)
+			}
+		}()
+		writer = limited
+	}
+
+	switch format {
+	case "csv":
+		if err := writeCSV(writer, h.newEventGenerator(logs, container.Tty), stripAnsi); err != nil {
+			log.Errorf("error while writing csv %v", err)
+		}
+	case "ndjson":
+		if err := writeNDJSON(writer, h.newEventGenerator(logs, container.Tty), stripAnsi); err != nil {
+			log.Errorf("error while writing ndjson %v", err)
+		}
+	case "zip":
+		if err := writeZip(writer, logs, container.Tty, stdTypes, stripAnsi, now); err != nil {
+			log.Errorf("error while writing zip %v", err)
+		}
+	case "bundle":
+		manifest := bundleManifest{To: to.UTC(), File: downloadFilename(h.config.DownloadFilename, container, now, "log.gz"), ExportedAt: now.UTC(), Version: h.config.Version}
+		manifest.Container.ID, manifest.Container.Name = container.ID, container.Name
+		if !from.IsZero() {
+			start := from.UTC()
+			manifest.From = &start
+		}
+		if err := writeBundle(writer, logs, container.Tty, stripAnsi, manifest); err != nil {
+			log.Errorf("error while writing bundle %v", err)
+		}
+	default:
+		if precompressed {
+			if err := copyWithoutTimestamps(writer, peeked, container.Tty); err != nil {
+				log.Warnf("download of %s ended early: %v", container.ID, err)
+			}
+			return
+		}
+		if timestamps || lineNumbers {
+			if err := writeLines(writer, h.newEventGenerator(logs, container.Tty), stripAnsi, timestamps, lineNumbers); err != nil {
+				log.Errorf("error while writing log lines %v", err)
+			}
+			return
+		}
+		var tail *tailWriter
+		out := writer
+		if tailBytes > 0 {
+			tail = newTailWriter(tailBytes)
+			writer = tail
+		}
+		if stripAnsi {
+			writer = ansiStrippingWriter{writer}
+		}
+		if container.Tty {
+			_, err = io.Copy(writer, logs)
+		} else if demux == docker.STDOUT {
+			_, err = stdcopy.StdCopy(writer, io.Discard, logs)
+		} else if demux == docker.STDERR {
+			_, err = stdcopy.StdCopy(io.Discard, writer, logs)
+		} else if annotateStreams {
+			_, err = stdcopy.StdCopy(&linePrefixWriter{w: writer, prefix: []byte("[stdout] ")}, &linePrefixWriter{w: writer, prefix: []byte("[stderr] ")}, logs)
+		} else {
+			_, err = stdcopy.StdCopy(writer, writer, logs)
+		}
+		if err != nil {
+			log.Warnf("download of %s ended early: %v", container.ID, err)
+		}
+		// written even when reading ended early so the client gets the end of what was read
+		if tail != nil {
+			if _, err := tail.WriteTo(out); err != nil {
+				log.Warnf("error while writing the tail of %s: %v", container.ID, err)
+			}
+		}
+	}
+}
+
+// downloadReader opens the logs of a download, retrying errors that may go away with a growing backoff. Nothing
+// has been written to the response yet, which is what makes retrying safe.
+func (h *handler) downloadReader(r *http.Request, id string, from time.Time, to time.Time, stdTypes docker.StdType) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		reader, err := h.clientFromRequest(r).ContainerLogsBetweenDates(r.Context(), id, from, to, stdTypes, false)
+		if err == nil || attempt >= h.config.DownloadRetries || errdefs.IsNotFound(err) || errdefs.IsInvalidParameter(err) || docker.IsLogDriverUnsupported(err) {
+			return reader, err
+		}
+		log.WithError(err).WithFields(log.Fields{"id": id, "attempt": attempt + 1}).Warn("retrying to open logs for download")
+		select {
+		case <-time.After(downloadRetryBackoff << attempt):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+type exportFormat struct {
+	extension, contentType, encoding string
+	// the options that only work on the bytes of the logs, not on formats that encode every event
+	supportsTail, supportsLineNumbers, supportsDemux bool
+	// maxBytes would cut archives short and break them
+	supportsMaxBytes bool
+	// recompress=false can only pass logs that are already gzip through a gzip download
+	supportsRecompress bool
+}
+
+// downloadFormats maps the format query parameter to the file extension, content type, compression and options
+// of the download. format=txt streams the logs uncompressed and zip archives are already compressed.
+var downloadFormats = map[string]exportFormat{
+	"":       {extension: "log", contentType: "application/text", encoding: "gzip", supportsTail: true, supportsLineNumbers: true, supportsDemux: true, supportsRecompress: true, supportsMaxBytes: true},
+	"gzip":   {extension: "log", contentType: "application/text", encoding: "gzip", supportsTail: true, supportsLineNumbers: true, supportsDemux: true, supportsRecompress: true, supportsMaxBytes: true},
+	"br":     {extension: "log", contentType: "application/text", encoding: "br", supportsTail: true, supportsLineNumbers: true, supportsDemux: true, supportsMaxBytes: true},
+	"txt":    {extension: "log", contentType: "text/plain; charset=UTF-8", supportsTail: true, supportsLineNumbers: true, supportsDemux: true, supportsMaxBytes: true},
+	"csv":    {extension: "csv", contentType: "text/csv; charset=UTF-8", encoding: "gzip", supportsMaxBytes: true},
+	"ndjson": {extension: "ndjson", contentType: "application/x-ndjson; charset=UTF-8", encoding: "gzip", supportsMaxBytes: true},
+	"zip":    {extension: "zip", contentType: "application/zip"},
+	"bundle": {extension: "zip", contentType: "application/zip"},
+}
+
+// compressedFiles is the extension and content type of a download saved compressed because the client
+// doesn't accept its encoding
+var compressedFiles = map[string]struct{ extension, contentType string }{
+	"gzip": {".gz", "application/gzip"},
+	"br":   {".br", "application/x-brotli"},
+}
+
+func setDownloadHeaders(w http.ResponseWriter, r *http.Request, format string, filename string) {
+	contentType := downloadFormats[format].contentType
+	encoding := downloadFormats[format].encoding
+	contentDisposition := "attachment; filename=" + filename
+	if encoding == "" {
+		w.Header().Set("Content-Disposition", contentDisposition)
+		w.Header().Set("Content-Type", contentType)
+	} else if strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+		w.Header().Set("Content-Disposition", contentDisposition)
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("Content-Type", contentType)
+	} else {
+		w.Header().Set("Content-Disposition", contentDisposition+compressedFiles[encoding].extension)
+		w.Header().Set("Content-Type", compressedFiles[encoding].contentType)
+	}
+}
+
+// headDownloadLogs reports the headers of a download without reading any logs
+func (h *handler) headDownloadLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		log.Debugf("unable to find container %s: %v", id, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	downloadFormat, ok := downloadFormats[format]
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	filename := downloadFilename(h.config.DownloadFilename, container, time.Now(), downloadFormat.extension)
+	setDownloadHeaders(w, r, format, filename)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ansiStrippingWriter removes ANSI escape sequences from every write. Sequences split across writes are not
+// detected, which is fine for log frames that always end on a line.
+type ansiStrippingWriter struct {
+	w io.Writer
+}
+
+func (a ansiStrippingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(a.w, docker.StripANSI(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// linePrefixWriter writes prefix at the start of every line. A line split across writes only gets it once.
+type linePrefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	midLine bool
+}
+
+func (l *linePrefixWriter) Write(p []byte) (int, error) {
+	for rest := p; len(rest) > 0; {
+		if !l.midLine {
+			if _, err := l.w.Write(l.prefix); err != nil {
+				return 0, err
+			}
+		}
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		if _, err := l.w.Write(line); err != nil {
+			return 0, err
+		}
+		l.midLine = line[len(line)-1] != '\n'
+		rest = rest[len(line):]
+	}
+	return len(p), nil
+}
+
+// zipSpoolLimit is the most bytes of stderr spooled to disk for a zip download, the rest is left out of stderr.log
+var zipSpoolLimit int64 = 256 << 20
+
+// overflowWriter drops the writes a limitedWriter refuses instead of failing them, so the other stream of a
+// demuxed copy keeps going
+type overflowWriter struct {
+	*limitedWriter
+}
+
+func (o overflowWriter) Write(p []byte) (int, error) {
+	if _, err := o.limitedWriter.Write(p); err != nil && !errors.Is(err, errDownloadLimit) {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeZip archives stdout and stderr as separate entries. A zip can only write one entry at a time so
+// stderr is spooled to a temp file, up to zipSpoolLimit, while stdout streams. Tty containers have no demux and
+// get a single entry.
+func writeZip(w io.Writer, reader io.Reader, tty bool, stdTypes docker.StdType, stripAnsi bool, modified time.Time) (err error) {
+	archive := zip.NewWriter(w)
+	// closed on every return so the entries written so far still make a valid archive
+	defer func() {
+		if closeErr := archive.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	create := func(name string) (io.Writer, error) {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil || !stripAnsi {
+			return entry, err
+		}
+		return ansiStrippingWriter{entry}, nil
+	}
+
+	if tty {
+		entry, err := create("combined.log")
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, reader)
+		return err
+	}
+
+	stdout, stderr := io.Discard, io.Discard
+	if stdTypes&docker.STDOUT != 0 {
+		entry, err := create("stdout.log")
+		if err != nil {
+			return err
+		}
+		stdout = entry
+	}
+
+	var spool *os.File
+	var spooled *limitedWriter
+	if stdTypes&docker.STDERR != 0 {
+		if spool, err = os.CreateTemp("", "dozzle-stderr-*.log"); err != nil {
+			return err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		spooled = &limitedWriter{w: spool, max: zipSpoolLimit}
+		stderr = overflowWriter{spooled}
+	}
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, reader); err != nil {
+		return err
+	}
+
+	if spool != nil {
+		entry, err := create("stderr.log")
+		if err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(entry, spool); err != nil {
+			return err
+		}
+		if spooled.reached {
+			log.Debugf("stderr of zip download truncated at %d bytes", zipSpoolLimit)
+			fmt.Fprint(entry, truncatedNote)
+		}
+	}
+
+	return nil
+}
+
+// writeLines writes each message prefixed with its timestamp, its line number or both. Continuation lines of
+// multi-line messages are written as they are so the prefix only marks where a message starts. Lines are
+// numbered per stream so the numbers match a download of only that stream.
+func writeLines(w io.Writer, g *docker.EventGenerator, stripAnsi bool, timestamps bool, lineNumbers bool) error {
+	numbers := make(map[string]int)
+	for event := range g.Events {
+		message := strings.TrimSuffix(messageText(event), "\n")
+		if stripAnsi {
+			message = docker.StripANSI(message)
+		}
+		if timestamps {
+			message = time.UnixMilli(event.Timestamp).UTC().Format(time.RFC3339Nano) + " " + message
+		}
+		if lineNumbers {
+			numbers[event.Stream]++
+			message = fmt.Sprintf("%06d %s", numbers[event.Stream], message)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, g *docker.EventGenerator, stripAnsi bool) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"timestamp", "stream", "message"}); err != nil {
+		return err
+	}
+
+	for event := range g.Events {
+		message := messageText(event)
+		if stripAnsi {
+			message = docker.StripANSI(message)
+		}
+		record := []string{
+			time.UnixMilli(event.Timestamp).UTC().Format(time.RFC3339Nano),
+			event.Stream,
+			message,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportedLogEvent is the shape of an event in ndjson downloads, using descriptive keys for ingestion pipelines
+type exportedLogEvent struct {
+	Timestamp string `json:"timestamp"`
+	Stream    string `json:"stream"`
+	Level     string `json:"level,omitempty"`
+	Message   any    `json:"message"`
+}
+
+func writeNDJSON(w io.Writer, g *docker.EventGenerator, stripAnsi bool) error {
+	encoder := json.NewEncoder(w)
+	for event := range g.Events {
+		message := event.Message
+		if text, ok := message.(string); ok && stripAnsi {
+			message = docker.StripANSI(text)
+		}
+		if err := encoder.Encode(exportedLogEvent{
+			Timestamp: time.UnixMilli(event.Timestamp).UTC().Format(time.RFC3339Nano),
+			Stream:    event.Stream,
+			Level:     event.Level,
+			Message:   message,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the client listed gzip in Accept-Encoding without disabling it with q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
+
+// parseTime parses an RFC3339 timestamp or a duration like -1h relative to now
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(value, "-") {
+		d, err := time.ParseDuration(value[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// stdTypesFromRequest returns the streams asked for with stdout and stderr or the server default when neither
+// is there. It is 0 when the server is strict about it, which handlers reject.
+func (h *handler) stdTypesFromRequest(r *http.Request) docker.StdType {
+	var stdTypes docker.StdType
+	if r.URL.Query().Has("stdout") {
+		stdTypes |= docker.STDOUT
+	}
+	if r.URL.Query().Has("stderr") {
+		stdTypes |= docker.STDERR
+	}
+
+	if stdTypes != 0 || h.config.StrictStdTypes {
+		return stdTypes
+	}
+	if h.config.DefaultStdTypes == 0 {
+		return docker.STDALL
+	}
+	return h.config.DefaultStdTypes
+}
+
+// timeRangeFromRequest reads from and to, a missing from is the beginning of the logs and a missing to is now
+func timeRangeFromRequest(r *http.Request) (from time.Time, to time.Time, err error) {
+	return timeRangeFromParams(r, "from", "to")
+}
+
+// timeRangeFromParams is timeRangeFromRequest for other parameter names
+func timeRangeFromParams(r *http.Request, fromParam string, toParam string) (from time.Time, to time.Time, err error) {
+	now := time.Now()
+	to = now
+	if value := r.URL.Query().Get(fromParam); value != "" {
+		if from, err = parseTime(value, now); err != nil {
+			return from, to, fmt.Errorf("invalid %s %q: must be a RFC3339 timestamp or a duration like -1h", fromParam, value)
+		}
+	}
+	if value := r.URL.Query().Get(toParam); value != "" {
+		if to, err = parseTime(value, now); err != nil {
+			return from, to, fmt.Errorf("invalid %s %q: must be a RFC3339 timestamp or a duration like -1h", toParam, value)
+		}
+	}
+	return from, to, nil
+}
+
+func (h *handler) fetchLogsBetweenDates(w http.ResponseWriter, r *http.Request) {
+	from, to, err := timeRangeFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.writeLogsBetweenDates(w, r, nil, from, to)
+}
+
+// fetchLogsSinceStart is fetchLogsBetweenDates from the time the container last started until now
+func (h *handler) fetchLogsSinceStart(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	if container.Started.IsZero() {
+		http.Error(w, "container has not been started", http.StatusConflict)
+		return
+	}
+
+	h.writeLogsBetweenDates(w, r, &container, container.Started, time.Now())
+}
+
+// writeLogsBetweenDates looks up the container unless the caller already has it
+func (h *handler) writeLogsBetweenDates(w http.ResponseWriter, r *http.Request, container *docker.Container, from time.Time, to time.Time) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// format=json wraps all events in a single array instead of one object per line
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "jsonl" && format != "json" {
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	location, err := locationFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// limit and offset page through events, the page is buffered so the returned count can be sent as a header
+	paginate := r.URL.Query().Has("limit") || r.URL.Query().Has("offset")
+	limit, offset := -1, 0
+	if r.URL.Query().Has("limit") {
+		if limit, err = strconv.Atoi(r.URL.Query().Get("limit")); err != nil || limit < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if r.URL.Query().Has("offset") {
+		if offset, err = strconv.Atoi(r.URL.Query().Get("offset")); err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// order=desc sends the newest events first. Only the newest offset+limit events are kept while reading,
+	// so a limit is required to bound memory.
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+	descending := order == "desc"
+	if descending && limit < 0 {
+		http.Error(w, "order=desc requires a limit", http.StatusBadRequest)
+		return
+	}
+
+	// details=true asks Docker for the attributes it attaches to each line with --log-opt labels, env or tag
+	details := r.URL.Query().Get("details") == "true"
+
+	// sanitizeUtf8=true replaces invalid UTF-8 before the event is encoded
+	sanitizeUtf8 := r.URL.Query().Get("sanitizeUtf8") == "true"
+
+	// timing=true reports time spent reading from Docker and encoding events in a Server-Timing trailer
+	timing := r.URL.Query().Get("timing") == "true"
+	started := time.Now()
+
+	if container == nil {
+		found, err := h.clientFromRequest(r).FindContainer(id)
+		if err != nil {
+			writeContainerNotFound(w, http.StatusNotFound, id, err)
+			return
+		}
+		container = &found
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reader, err := h.clientFromRequest(r).ContainerLogsBetweenDates(ctx, container.ID, from, to, stdTypes, details)
+	if docker.IsLogDriverUnsupported(err) {
+		writeLogDriverUnsupported(w, *container)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var source io.Reader = reader
+	var timed *timedReader
+	var encoding time.Duration
+	if timing {
+		timed = &timedReader{reader: reader}
+		source = timed
+		// the body is streamed, so the timings can only be sent after it
+		w.Header().Set("Trailer", "Server-Timing")
+		defer func() {
+			w.Header().Set("Server-Timing", formatServerTiming(
+				serverTiming{"docker", timed.Duration()},
+				serverTiming{"encode", encoding},
+				serverTiming{"total", time.Since(started)},
+			))
+		}()
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-jsonl; charset=UTF-8")
+	}
+
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+		zw := gzip.NewWriter(w)
+		defer func() {
+			if err := zw.Close(); err != nil {
+				log.Warnf("error while closing gzip writer: %v", err)
+			}
+		}()
+		out = zw
+	}
+
+	g := h.newEventGeneratorWithDetails(source, container.Tty, details)
+	encoder := json.NewEncoder(out)
+
+	first := true
+	writeEvent := func(event *docker.LogEvent) {
+		if timing {
+			start := time.Now()
+			defer func() { encoding += time.Since(start) }()
+		}
+		if sanitizeUtf8 {
+			sanitizeMessage(event)
+		}
+		setEventTime(event, location)
+		if format == "json" {
+			if first {
+				fmt.Fprint(out, "[")
+			} else {
+				fmt.Fprint(out, ",")
+			}
+		}
+		first = false
+		if err := encoder.Encode(event); err != nil {
+			log.Errorf("json encoding error while streaming %v", err.Error())
+		}
+	}
+
+	var page []*docker.LogEvent
+	skipped := 0
+	for event := range g.Events {
+		if !filter.matches(event) {
+			continue
+		}
+		if !paginate {
+			writeEvent(event)
+			continue
+		}
+		if descending {
+			page = append(page, event)
+			if len(page) > offset+limit {
+				page = page[1:]
+			}
+			continue
+		}
+		if skipped < offset {
+			skipped++
+		} else if limit < 0 || len(page) < limit {
+			page = append(page, event)
+		} else {
+			// page is full, stop reading from docker and drain what is left
+			cancel()
+		}
+	}
+
+	if descending {
+		sort.SliceStable(page, func(i, j int) bool {
+			return page[i].Timestamp > page[j].Timestamp
+		})
+		page = page[min(offset, len(page)):]
+		page = page[:min(limit, len(page))]
+	}
+
+	if paginate {
+		w.Header().Set("X-Total-Returned", strconv.Itoa(len(page)))
+		for _, event := range page {
+			writeEvent(event)
+		}
+	}
+
+	// an empty window still gets a body so clients can tell it apart from a failed request
+	if format == "json" {
+		if first {
+			fmt.Fprint(out, "[")
+		}
+		fmt.Fprint(out, "]\n")
+	} else if first {
+		fmt.Fprint(out, "{\"type\":\"empty\"}\n")
+	}
+}
+
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+// countLogs counts the events fetchLogsBetweenDates would return without sending any of them
+func (h *handler) countLogs(w http.ResponseWriter, r *http.Request) {
+	from, to, err := timeRangeFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	now := time.Now()
-	nowFmt := now.Format("2006-01-02T15-04-05")
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
 
-	contentDisposition := fmt.Sprintf("attachment; filename=%s-%s.log", container.Name, nowFmt)
+	reader, err := h.clientFromRequest(r).ContainerLogsBetweenDates(r.Context(), container.ID, from, to, stdTypes, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
 
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w.Header().Set("Content-Disposition", contentDisposition)
-		w.Header().Set("Content-Encoding", "gzip")
-		w.Header().Set("Content-Type", "application/text")
-	} else {
-		w.Header().Set("Content-Disposition", contentDisposition+".gz")
-		w.Header().Set("Content-Type", "application/gzip")
+	g := h.newEventGenerator(reader, container.Tty)
+	count := 0
+	for event := range g.Events {
+		if filter.matches(event) {
+			count++
+		}
 	}
 
-	var stdTypes docker.StdType
-	if r.URL.Query().Has("stdout") {
-		stdTypes |= docker.STDOUT
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(countResponse{Count: count}); err != nil {
+		log.Errorf("json encoding error while writing count %v", err)
 	}
-	if r.URL.Query().Has("stderr") {
-		stdTypes |= docker.STDERR
+}
+
+// sanitizeMessage replaces invalid UTF-8 in text and logfmt messages with the replacement character
+func sanitizeMessage(event *docker.LogEvent) {
+	switch message := event.Message.(type) {
+	case string:
+		if !utf8.ValidString(message) {
+			event.Message = strings.ToValidUTF8(message, "\uFFFD")
+		}
+	case map[string]string:
+		for key, value := range message {
+			if !utf8.ValidString(key) || !utf8.ValidString(value) {
+				delete(message, key)
+				message[strings.ToValidUTF8(key, "\uFFFD")] = strings.ToValidUTF8(value, "\uFFFD")
+			}
+		}
 	}
+}
 
-	if stdTypes == 0 {
-		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+// truncateMessage cuts string messages longer than max bytes without splitting a rune
+func truncateMessage(event *docker.LogEvent, max int) {
+	message, ok := event.Message.(string)
+	if !ok || len(message) <= max {
 		return
 	}
+	end := max
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	event.Message = message[:end] + "…"
+	event.Truncated = true
+}
 
-	zw := gzip.NewWriter(w)
-	defer zw.Close()
-	zw.Name = fmt.Sprintf("%s-%s.log", container.Name, nowFmt)
-	zw.Comment = "Logs generated by Dozzle"
-	zw.ModTime = now
+// eventTimeFormat is the millisecond precision of LogEvent.Timestamp in RFC 3339
+const eventTimeFormat = "2006-01-02T15:04:05.000Z07:00"
 
-	reader, err := h.clientFromRequest(r).ContainerLogsBetweenDates(r.Context(), id, time.Time{}, now, stdTypes)
+// locationFromRequest loads the ?tz= zone, UTC or an IANA name. It returns nil when no zone was asked for.
+func locationFromRequest(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(tz)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, fmt.Errorf("invalid tz: %w", err)
+	}
+	return location, nil
+}
+
+// setEventTime formats the timestamp of an event in location for display. Timestamp stays in epoch millis so
+// ids and resuming don't depend on the zone.
+func setEventTime(event *docker.LogEvent, location *time.Location) {
+	if location == nil || event.Timestamp == 0 {
 		return
 	}
-	if container.Tty {
-		io.Copy(zw, reader)
+	event.Time = time.UnixMilli(event.Timestamp).In(location).Format(eventTimeFormat)
+}
+
+// extractJsonFields moves a JSON object message to Fields and replaces the message with its text. Messages that
+// are not JSON objects are left as they are.
+func extractJsonFields(event *docker.LogEvent, pretty bool) {
+	fields, ok := event.Message.(map[string]interface{})
+	if !ok {
+		message, isString := event.Message.(string)
+		if !isString || json.Unmarshal([]byte(message), &fields) != nil || fields == nil {
+			return
+		}
+	}
+
+	var buf []byte
+	var err error
+	if pretty {
+		buf, err = json.MarshalIndent(fields, "", "  ")
 	} else {
-		stdcopy.StdCopy(zw, zw, reader)
+		buf, err = json.Marshal(fields)
 	}
+	if err != nil {
+		log.Debugf("unable to encode json fields: %v", err)
+		return
+	}
+	event.Fields = fields
+	event.Message = string(buf)
 }
 
-func (h *handler) fetchLogsBetweenDates(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/x-jsonl; charset=UTF-8")
+// stoppedRetryFactor stretches the retry hint once a container stops since it is unlikely to be back right away
+const stoppedRetryFactor = 10
 
-	from, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("from"))
-	to, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("to"))
-	id := chi.URLParam(r, "id")
+func (h *handler) writeContainerStopped(w io.Writer) {
+	if h.config.SSERetry > 0 {
+		fmt.Fprintf(w, "retry: %d\n", (h.config.SSERetry * stoppedRetryFactor).Milliseconds())
+	}
+	fmt.Fprintf(w, "event: container-stopped\ndata: end of stream\n\n")
+}
 
-	var stdTypes docker.StdType
-	if r.URL.Query().Has("stdout") {
-		stdTypes |= docker.STDOUT
+// containerEnded is sent instead of container-stopped when the state of the container explains why its logs ended
+type containerEnded struct {
+	State    string `json:"state"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+}
+
+// writeContainerEnded looks up the container after its logs ended to tell a restart apart from an exit or a dead
+// container. It falls back to container-stopped when the state is anything else or can't be found.
+func (h *handler) writeContainerEnded(out streamWriter, client docker.Client, id string) {
+	container, err := client.FindContainer(id)
+	if err != nil {
+		log.Debugf("unable to find container %s after its logs ended: %v", id, err)
+		out.stopped(h.config.SSERetry * stoppedRetryFactor)
+		return
 	}
-	if r.URL.Query().Has("stderr") {
-		stdTypes |= docker.STDERR
+
+	ended := containerEnded{State: container.State}
+	switch container.State {
+	case "restarting":
+	case "exited", "dead":
+		ended.ExitCode = &container.ExitCode
+	default:
+		out.stopped(h.config.SSERetry * stoppedRetryFactor)
+		return
+	}
+
+	if buf, err := json.Marshal(ended); err == nil {
+		out.control("container-"+container.State, string(buf))
+	}
+}
+
+// writeServerShutdown tells the client the stream ended because the server is going away, not the container
+func writeServerShutdown(w io.Writer) {
+	fmt.Fprintf(w, "event: server-shutdown\ndata: %s\n\n", serverShutdown.Data)
+}
+
+// streamControl is what streams without SSE framing send in place of a named SSE event
+type streamControl struct {
+	Event string `json:"event"`
+	Data  string `json:"data"`
+}
+
+var serverShutdown = streamControl{Event: "server-shutdown", Data: "server is shutting down"}
+
+// containerInfo is sent once at the start of a stream
+type containerInfo struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Tty       bool              `json:"tty"`
+	StartedAt *time.Time        `json:"startedAt,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// containerInfo only includes the labels listed in StreamLabels so streams don't carry every label
+func (h *handler) containerInfo(container docker.Container) containerInfo {
+	info := containerInfo{ID: container.ID, Name: container.Name, Tty: container.Tty}
+	if !container.Started.IsZero() {
+		info.StartedAt = &container.Started
+	}
+	for _, key := range h.config.StreamLabels {
+		if value, ok := container.Labels[key]; ok {
+			if info.Labels == nil {
+				info.Labels = make(map[string]string)
+			}
+			info.Labels[key] = value
+		}
 	}
+	return info
+}
+
+// recreatePollInterval is how often follow=name looks for a recreated container
+var recreatePollInterval = time.Second
 
+// defaultStatsInterval is how often stats=true sends the stats of the container
+const defaultStatsInterval = 5 * time.Second
+
+// minStatsInterval keeps statsInterval from asking for stats faster than Docker sends them
+var minStatsInterval = time.Second
+
+// stackTraceTimeout is how long joinStackTraces=true waits for more continuation lines before sending a trace
+var stackTraceTimeout = 500 * time.Millisecond
+
+// downloadRetryBackoff is the wait before the first retry of a download, doubling with every retry after
+var downloadRetryBackoff = 500 * time.Millisecond
+
+// reconnectBackoff is the wait before the first reconnect attempt, doubling with every attempt after
+var reconnectBackoff = time.Second
+
+func (h *handler) streamLogs(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
 	if stdTypes == 0 {
 		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
 		return
 	}
 
-	container, err := h.clientFromRequest(r).FindContainer(id)
+	filter, err := filterFromRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	reader, err := h.clientFromRequest(r).ContainerLogsBetweenDates(r.Context(), container.ID, from, to, stdTypes)
+	tail := docker.DefaultTail
+	if r.URL.Query().Has("tail") {
+		tail, err = strconv.Atoi(r.URL.Query().Get("tail"))
+		if err != nil || tail < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var maxDuration time.Duration
+	if r.URL.Query().Has("maxDuration") {
+		maxDuration, err = time.ParseDuration(r.URL.Query().Get("maxDuration"))
+		if err != nil || maxDuration <= 0 {
+			http.Error(w, "maxDuration must be a positive duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// debugStats sends the runtime mem stats with every keep-alive ping
+	debugStats := r.URL.Query().Get("debugStats") == "true"
+
+	dedupe := r.URL.Query().Get("dedupe") == "true"
+
+	// joinStackTraces=true merges continuation lines, indented or matching ?continuation=, into the line before them
+	joiner, err := stackTraceJoinerFromRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	g := docker.NewEventGenerator(reader, container.Tty)
-	encoder := json.NewEncoder(w)
+	// follow=false sends the last tail lines and ends like docker logs --tail without -f. follow=name keeps the
+	// stream open when the container is removed and attaches to the next one with the same name.
+	follow := r.URL.Query().Get("follow")
+	if follow != "" && follow != "true" && follow != "false" && follow != "name" {
+		http.Error(w, "follow must be true, false or name", http.StatusBadRequest)
+		return
+	}
+	followName := follow == "name"
 
-	for event := range g.Events {
-		if err := encoder.Encode(event); err != nil {
-			log.Errorf("json encoding error while streaming %v", err.Error())
+	// maxRate=N samples the stream down to about N events per second when it is faster than that
+	var sampler *tokenBucket
+	if r.URL.Query().Has("maxRate") {
+		maxRate, err := strconv.Atoi(r.URL.Query().Get("maxRate"))
+		if err != nil || maxRate <= 0 {
+			http.Error(w, "maxRate must be a positive integer", http.StatusBadRequest)
+			return
 		}
+		sampler = newTokenBucket(maxRate)
 	}
-}
 
-func (h *handler) streamLogs(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	maxLineLength := 0
+	if r.URL.Query().Has("maxLineLength") {
+		if maxLineLength, err = strconv.Atoi(r.URL.Query().Get("maxLineLength")); err != nil || maxLineLength <= 0 {
+			http.Error(w, "maxLineLength must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
 
-	var stdTypes docker.StdType
-	if r.URL.Query().Has("stdout") {
-		stdTypes |= docker.STDOUT
+	location, err := locationFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if r.URL.Query().Has("stderr") {
-		stdTypes |= docker.STDERR
+
+	// parseJson=true sends JSON messages as fields with the raw text as the message, prettyJson=true indents it
+	parseJson := r.URL.Query().Get("parseJson") == "true"
+	prettyJson := r.URL.Query().Get("prettyJson") == "true"
+
+	// details=true asks Docker for the attributes it attaches to each line with --log-opt labels, env or tag
+	details := r.URL.Query().Get("details") == "true"
+
+	// sanitizeUtf8=true replaces invalid UTF-8 before the event is encoded, so lengths and match offsets are
+	// those of the text the client receives
+	sanitizeUtf8 := r.URL.Query().Get("sanitizeUtf8") == "true"
+
+	// stats=true interleaves the latest cpu and memory usage of the container as stats events every statsInterval
+	withStats := r.URL.Query().Get("stats") == "true"
+	statsInterval := defaultStatsInterval
+	if r.URL.Query().Has("statsInterval") {
+		if statsInterval, err = time.ParseDuration(r.URL.Query().Get("statsInterval")); err != nil || statsInterval < minStatsInterval {
+			http.Error(w, fmt.Sprintf("statsInterval must be a duration of at least %s", minStatsInterval), http.StatusBadRequest)
+			return
+		}
 	}
 
-	if stdTypes == 0 {
-		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
-		return
+	// rawStream=true adds the StdType bit of the stream as streamType: 1 unknown, 2 stdout and 4 stderr
+	rawStream := r.URL.Query().Get("rawStream") == "true"
+
+	// fields=timestamp,message only sends the listed fields of each event
+	keepFields := eventFieldsFromRequest(r)
+
+	// JSON over SSE stays the default, protocol buffers are only sent to clients that ask for them
+	var out streamWriter = &sseWriter{w: w, keepFields: keepFields}
+	if acceptsProtobuf(r) {
+		if keepFields != nil {
+			http.Error(w, "fields is not supported with protocol buffers", http.StatusBadRequest)
+			return
+		}
+		out = &protobufWriter{w: w}
+		// strings in protocol buffers must be valid UTF-8
+		sanitizeUtf8 = true
 	}
 
 	f, ok := w.(http.Flusher)
@@ -141,84 +1292,534 @@ func (h *handler) streamLogs(w http.ResponseWriter, r *http.Request) {
 
 	container, err := h.clientFromRequest(r).FindContainer(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-transform")
-	w.Header().Add("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
 	lastEventId := r.Header.Get("Last-Event-ID")
 	if len(r.URL.Query().Get("lastEventId")) > 0 {
 		lastEventId = r.URL.Query().Get("lastEventId")
 	}
 
-	reader, err := h.clientFromRequest(r).ContainerLogs(r.Context(), container.ID, lastEventId, stdTypes)
-	if err != nil {
-		if err == io.EOF {
-			fmt.Fprintf(w, "event: container-stopped\ndata: end of stream\n\n")
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// sinceNow=true skips the history and only sends new logs, unless the client is resuming from lastEventId
+	since, resumeTimestamp, skip := resumeFromEventId(lastEventId)
+	if lastEventId == "" && r.URL.Query().Get("sinceNow") == "true" {
+		since = strconv.FormatInt(time.Now().UnixMilli(), 10)
+	}
+
+	// the reader is opened before switching to SSE so that errors are returned as a plain response
+	var reader io.ReadCloser
+	if follow == "false" {
+		reader, err = h.clientFromRequest(r).ContainerLogsTail(ctx, container.ID, tail, stdTypes, details)
+	} else {
+		reader, err = h.clientFromRequest(r).ContainerLogs(ctx, container.ID, since, tail, stdTypes, details)
+	}
+	unsupported := docker.IsLogDriverUnsupported(err)
+	if err != nil && err != io.EOF && !unsupported {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out.setHeaders(w.Header())
+
+	if h.config.SSERetry > 0 {
+		out.retry(h.config.SSERetry)
+	}
+
+	if buf, err := json.Marshal(h.containerInfo(container)); err == nil {
+		out.control("container-info", string(buf))
+	}
+
+	if err == io.EOF {
+		out.stopped(h.config.SSERetry * stoppedRetryFactor)
+		f.Flush()
+		return
+	}
+
+	// the stream still opens so the client hears why there are no logs instead of retrying a failed request
+	if unsupported {
+		if buf, err := json.Marshal(map[string]string{"driver": container.LogDriver}); err == nil {
+			out.control("unsupported-log-driver", string(buf))
+		}
+		f.Flush()
+		return
+	}
+
+	// Docker sends stats about every second, only the latest is kept until the next tick
+	var stats chan docker.ContainerStat
+	var statsTick <-chan time.Time
+	var latestStat *docker.ContainerStat
+	if withStats {
+		stats = make(chan docker.ContainerStat, 1)
+		go func(client docker.Client, id string) {
+			if err := client.ContainerStats(ctx, id, stats); err != nil && ctx.Err() == nil && err != io.EOF {
+				log.Debugf("stats of %s ended: %v", id, err)
+			}
+		}(h.clientFromRequest(r), container.ID)
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+		statsTick = ticker.C
+	}
+
+	// noPing=true is for clients that keep the connection alive themselves, a nil channel never fires
+	var ping <-chan time.Time
+	if r.URL.Query().Get("noPing") != "true" {
+		ticker := time.NewTicker(h.config.KeepAliveInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	// events sharing the timestamp of the event before them, or without one, get a synthetic id of the last seen
+	// timestamp and a counter. Resuming carries on counting where the client left off.
+	lastTimestamp, sequence := resumeTimestamp, skip-1
+	if skip == 0 {
+		sequence = 0
+	}
+
+	// seq orders the events of this connection even when their timestamps tie, it starts over with every new connection
+	var seq int64
+
+	// streamed counts the bytes of the events sent on this connection for the summary logged when it ends
+	var streamed uint64
+	defer func() {
+		if log.IsLevelEnabled(log.DebugLevel) {
+			log.WithFields(memStats()).WithFields(log.Fields{
+				"id":       id,
+				"events":   seq,
+				"bytes":    humanize.Bytes(streamed),
+				"duration": time.Since(started).Round(time.Millisecond),
+			}).Debug("log stream summary")
+		}
+	}()
+
+	var timeout <-chan time.Time
+	if maxDuration > 0 {
+		timeout = time.After(maxDuration)
+	}
+
+	// the read timeout ends the stream when Docker sends nothing for that long, which pings alone can't detect
+	var readTimer *time.Timer
+	var readTimeout <-chan time.Time
+	if h.config.StreamReadTimeout > 0 {
+		readTimer = time.NewTimer(h.config.StreamReadTimeout)
+		defer readTimer.Stop()
+		readTimeout = readTimer.C
+	}
+	resetReadTimeout := func() {
+		if readTimer == nil {
+			return
+		}
+		if !readTimer.Stop() {
+			select {
+			case <-readTimer.C:
+			default:
+			}
+		}
+		readTimer.Reset(h.config.StreamReadTimeout)
+	}
+
+	// reconnect reopens the logs after the last seen event, backing off between attempts
+	attempts := 0
+	reconnect := func(cause error) (io.ReadCloser, error) {
+		err := cause
+		for attempts < h.config.StreamRetries && out.err() == nil {
+			attempts++
+			log.WithError(err).WithFields(log.Fields{"id": id, "attempt": attempts}).Warn("reconnecting to log stream")
+			out.control("reconnecting", strconv.Itoa(attempts))
 			f.Flush()
+
+			select {
+			case <-time.After(reconnectBackoff << (attempts - 1)):
+			case <-ctx.Done():
+				return nil, context.Canceled
+			}
+
+			resume := since
+			if lastTimestamp > 0 {
+				resume, resumeTimestamp, skip = resumeFromEventId(fmt.Sprintf("%d-%d", lastTimestamp, sequence))
+			}
+
+			var reader io.ReadCloser
+			if reader, err = h.clientFromRequest(r).ContainerLogs(ctx, container.ID, resume, tail, stdTypes, details); err == nil || err == io.EOF {
+				return reader, err
+			}
+		}
+		return nil, err
+	}
+
+	emit := func(event *docker.LogEvent) {
+		// a resumed stream starts at the timestamp of the last event id, the events the client already has are skipped
+		if skip > 0 {
+			if event.Timestamp == resumeTimestamp {
+				skip--
+				return
+			}
+			skip = 0
+		}
+		if sanitizeUtf8 {
+			sanitizeMessage(event)
+		}
+		if parseJson {
+			extractJsonFields(event, prettyJson)
+		}
+		if maxLineLength > 0 {
+			truncateMessage(event, maxLineLength)
+		}
+		seq++
+		event.Seq = seq
+		if rawStream {
+			event.StreamType = docker.ParseStdType(event.Stream)
+		}
+		setEventTime(event, location)
+		var eventId string
+		if event.Timestamp > 0 && event.Timestamp != lastTimestamp {
+			lastTimestamp, sequence = event.Timestamp, 0
+			eventId = strconv.FormatInt(event.Timestamp, 10)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			sequence++
+			eventId = fmt.Sprintf("%d-%d", lastTimestamp, sequence)
+		}
+		streamed += uint64(out.event(event, filter.highlight(event), eventId))
+		f.Flush()
+	}
+
+	// dedupe holds back consecutive identical messages. The last one is sent with the number that were held
+	// back when a different message arrives or on the next ping.
+	var lastMessage string
+	var repeat *docker.LogEvent
+	var repeated int
+	flushRepeated := func() {
+		if repeated > 0 {
+			repeat.Repeated = repeated
+			emit(repeat)
+			repeated = 0
+		}
+	}
+
+	// waitForRecreated polls for a new running container with the same name, keeping the stream alive with pings.
+	// ended is set when it gave up because of maxDuration or a server shutdown.
+	ended := false
+	waitForRecreated := func() (docker.Container, bool) {
+		poll := time.NewTicker(recreatePollInterval)
+		defer poll.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return docker.Container{}, false
+			case <-timeout:
+				out.control("stream-timeout", maxDuration.String())
+				f.Flush()
+				ended = true
+				return docker.Container{}, false
+			case <-ping:
+				out.ping()
+				f.Flush()
+				if out.err() != nil {
+					return docker.Container{}, false
+				}
+			case <-h.shutdown:
+				out.control(serverShutdown.Event, serverShutdown.Data)
+				f.Flush()
+				ended = true
+				return docker.Container{}, false
+			case <-poll.C:
+				containers, err := h.clientFromRequest(r).ListContainers()
+				if err != nil {
+					log.Debugf("error while waiting for %s to be recreated: %v", container.Name, err)
+					continue
+				}
+				for _, c := range containers {
+					if c.Name == container.Name && c.ID != container.ID && c.State == "running" {
+						if next, err := h.clientFromRequest(r).FindContainer(c.ID); err == nil {
+							return next, true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// skipped counts the events dropped by maxRate since the last one that was sent
+	var skipped int
+	process := func(event *docker.LogEvent) {
+		if !filter.matches(event) {
+			return
+		}
+		if sampler != nil {
+			if !sampler.allow(time.Now()) {
+				skipped++
+				return
+			}
+			if skipped > 0 {
+				event.Sampled, event.Skipped = true, skipped
+				skipped = 0
+			}
+		}
+		if dedupe {
+			text := messageText(event)
+			if repeat != nil && text == lastMessage {
+				repeat, repeated = event, repeated+1
+				return
+			}
+			flushRepeated()
+			repeat, lastMessage = event, text
+		}
+		emit(event)
+	}
+
+	// a held back stack trace is sent when a line does not continue it or no line arrived for stackTraceTimeout
+	var traceTimer *time.Timer
+	var traceFlush <-chan time.Time
+	defer func() {
+		if traceTimer != nil {
+			traceTimer.Stop()
+		}
+	}()
+	flushTrace := func() {
+		if joiner == nil {
+			return
+		}
+		if event := joiner.flush(); event != nil {
+			process(event)
 		}
-		return
 	}
 
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	handle := func(event *docker.LogEvent) {
+		attempts = 0
+		if joiner == nil {
+			process(event)
+			return
+		}
+		if complete := joiner.add(event); complete != nil {
+			process(complete)
+		}
+		if traceTimer == nil {
+			traceTimer = time.NewTimer(stackTraceTimeout)
+			traceFlush = traceTimer.C
+			return
+		}
+		if !traceTimer.Stop() {
+			select {
+			case <-traceTimer.C:
+			default:
+			}
+		}
+		traceTimer.Reset(stackTraceTimeout)
+	}
 
-	g := docker.NewEventGenerator(reader, container.Tty)
+	// end writes the event that closes the stream after the events still held back by dedupe and joinStackTraces,
+	// so no way out of the loop below can lose them
+	var end func()
+	defer func() {
+		flushTrace()
+		flushRepeated()
+		if end != nil {
+			end()
+		}
+		f.Flush()
+	}()
 
-loop:
+stream:
 	for {
+		g := h.newEventGeneratorWithDetails(reader, container.Tty, details)
+
+		// the queue drops the oldest events instead of letting a slow client hold up the generator
+		queue := newEventQueue(h.config.StreamQueueSize)
+		go queue.pump(g)
+		resetReadTimeout()
+
+	loop:
+		for {
+			// a client that can't be written to is gone even if its context isn't done yet
+			if out.err() != nil {
+				log.WithError(out.err()).WithFields(log.Fields{"id": id}).Debug("error while writing to client, closing stream")
+				cancel()
+				return
+			}
+			select {
+			case <-queue.ready:
+				resetReadTimeout()
+				events, dropped, closed := queue.take()
+				if dropped > 0 {
+					log.WithFields(log.Fields{"id": id, "dropped": dropped}).Debug("client is too slow, dropped events")
+					out.control("dropped", strconv.Itoa(dropped))
+				}
+				for _, event := range events {
+					handle(event)
+				}
+				if closed {
+					log.WithFields(log.Fields{"id": id}).Debug("stream closed")
+					break loop
+				}
+			case <-traceFlush:
+				flushTrace()
+			case stat := <-stats:
+				latestStat = &stat
+			case <-statsTick:
+				if latestStat != nil {
+					if buf, err := json.Marshal(latestStat); err == nil {
+						out.control("stats", string(buf))
+						f.Flush()
+					}
+					latestStat = nil
+				}
+			case <-ping:
+				flushRepeated()
+				out.ping()
+				if debugStats {
+					if buf, err := json.Marshal(memStats()); err == nil {
+						out.control("debug-stats", string(buf))
+					}
+				}
+				f.Flush()
+			case <-timeout:
+				log.WithFields(log.Fields{"id": id}).Debug("stream reached max duration")
+				end = func() { out.control("stream-timeout", maxDuration.String()) }
+				// stop docker from sending more logs, the queue keeps draining the generator until it is done
+				cancel()
+				return
+			case <-readTimeout:
+				log.WithFields(log.Fields{"id": id, "timeout": h.config.StreamReadTimeout}).Warn("no logs read from docker before the read timeout, closing stream")
+				end = func() { out.control("read-timeout", h.config.StreamReadTimeout.String()) }
+				// closes the reader, the queue keeps draining the generator until it is done
+				cancel()
+				return
+			case <-h.shutdown:
+				log.WithFields(log.Fields{"id": id}).Debug("server is shutting down, closing stream")
+				end = func() { out.control(serverShutdown.Event, serverShutdown.Data) }
+				cancel()
+				return
+			}
+		}
+
+		flushTrace()
+		flushRepeated()
+
+		var err error
 		select {
-		case event, ok := <-g.Events:
-			if !ok {
-				log.WithFields(log.Fields{"id": id}).Debug("stream closed")
-				break loop
+		case err = <-g.Errors:
+		default:
+		}
+
+		if err != nil && err != io.EOF && err != context.Canceled && ctx.Err() == nil && follow != "false" {
+			if reader, err = reconnect(err); err == nil {
+				continue stream
 			}
-			if buf, err := json.Marshal(event); err != nil {
-				log.Errorf("json encoding error while streaming %v", err.Error())
-			} else {
-				fmt.Fprintf(w, "data: %s\n", buf)
+		}
+
+		if err == io.EOF && followName {
+			next, ok := waitForRecreated()
+			if ended {
+				return
 			}
-			if event.Timestamp > 0 {
-				fmt.Fprintf(w, "id: %d\n", event.Timestamp)
+			if ok {
+				log.Debugf("container %s was recreated as %s", container.ID, next.ID)
+				container = next
+				lastTimestamp, sequence = 0, 0
+				if buf, err := json.Marshal(h.containerInfo(container)); err == nil {
+					out.control("container-recreated", string(buf))
+					f.Flush()
+				}
+				if reader, err = h.clientFromRequest(r).ContainerLogs(ctx, container.ID, "", tail, stdTypes, details); err == nil {
+					continue stream
+				}
+			} else {
+				err = ctx.Err()
 			}
-			fmt.Fprintf(w, "\n")
-			f.Flush()
-		case <-ticker.C:
-			fmt.Fprintf(w, ":ping \n\n")
-			f.Flush()
 		}
-	}
 
-	select {
-	case err := <-g.Errors:
 		if err != nil {
 			if err == io.EOF {
 				log.Debugf("container stopped: %v", container.ID)
-				fmt.Fprintf(w, "event: container-stopped\ndata: end of stream\n\n")
+				h.writeContainerEnded(out, h.clientFromRequest(r), container.ID)
 				f.Flush()
 			} else if err != context.Canceled {
 				log.Errorf("unknown error while streaming %v", err.Error())
 			}
 		}
-	default:
+		break
+	}
+}
+
+// drainEvents reads what is left of g in the background so its goroutines can finish once the logs are closed
+func drainEvents(g *docker.EventGenerator) {
+	go func() {
+		for range g.Events {
+		}
+	}()
+}
+
+func memStats() map[string]any {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	// For info on each, see: https://golang.org/pkg/runtime/#MemStats
+	return map[string]any{
+		"allocated":      humanize.Bytes(m.Alloc),
+		"totalAllocated": humanize.Bytes(m.TotalAlloc),
+		"system":         humanize.Bytes(m.Sys),
+		"routines":       runtime.NumGoroutine(),
+	}
+}
+
+// sinceFromEventId converts a Last-Event-ID into the since value for ContainerLogs. Synthetic ids of the
+// form <timestamp>-<counter> resume from the timestamp, or from the beginning if none was seen yet.
+func sinceFromEventId(lastEventId string) string {
+	if index := strings.Index(lastEventId, "-"); index != -1 {
+		lastEventId = lastEventId[:index]
+	}
+	if lastEventId == "0" {
+		return ""
+	}
+	return lastEventId
+}
+
+// resumeFromEventId is sinceFromEventId for streams that number events sharing a timestamp. Since is just
+// before the timestamp of the id so the rest of a burst isn't lost, and skip is how many events with that
+// timestamp the client already has. Ids without a timestamp fall back to sinceFromEventId.
+func resumeFromEventId(lastEventId string) (since string, timestamp int64, skip int) {
+	value, counter, _ := strings.Cut(lastEventId, "-")
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || timestamp <= 0 {
+		return sinceFromEventId(lastEventId), 0, 0
 	}
+	skip = 1
+	if counter != "" {
+		if n, err := strconv.Atoi(counter); err == nil && n >= 0 {
+			skip = n + 1
+		}
+	}
+	// ContainerLogs starts a millisecond after since
+	return strconv.FormatInt(timestamp-1, 10), timestamp, skip
+}
 
-	if log.IsLevelEnabled(log.DebugLevel) {
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		// For info on each, see: https://golang.org/pkg/runtime/#MemStats
-		log.WithFields(log.Fields{
-			"allocated":      humanize.Bytes(m.Alloc),
-			"totalAllocated": humanize.Bytes(m.TotalAlloc),
-			"system":         humanize.Bytes(m.Sys),
-			"routines":       runtime.NumGoroutine(),
-		}).Debug("runtime mem stats")
+// skipResumedEvents drops the first skip lines of logs that have the timestamp of a resumed event id, the same
+// ones emit skips on a resumed stream. Lines are read as they come from Docker, with a frame header if not tty.
+func skipResumedEvents(logs io.Reader, tty bool, timestamp int64, skip int) io.Reader {
+	buffered := bufio.NewReader(logs)
+	header := 8
+	if tty {
+		header = 0
+	}
+	for ; skip > 0; skip-- {
+		peeked, _ := buffered.Peek(header + len(time.RFC3339Nano) + 1)
+		if len(peeked) < header {
+			break
+		}
+		prefix, _, found := bytes.Cut(peeked[header:], []byte(" "))
+		at, err := time.Parse(time.RFC3339Nano, string(prefix))
+		if !found || err != nil || at.UnixMilli() != timestamp {
+			break
+		}
+		if tty {
+			if _, err := buffered.ReadString('\n'); err != nil {
+				break
+			}
+		} else if _, err := buffered.Discard(header + int(binary.BigEndian.Uint32(peeked[4:8]))); err != nil {
+			break
+		}
 	}
+	return buffered
 }
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type boundsResponse struct {
+	First *time.Time `json:"first"`
+	Last  *time.Time `json:"last"`
+}
+
+// fetchLogBounds returns the timestamps of the first and last log events of a container, null when it has none
+func (h *handler) fetchLogBounds(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	client := h.clientFromRequest(r)
+	container, err := client.FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	// logs can't be older than the container, which keeps Docker from looking before it
+	from := time.Time{}
+	if container.Created > 0 {
+		from = time.Unix(container.Created, 0)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	reader, err := client.ContainerLogsBetweenDates(ctx, container.ID, from, time.Now(), stdTypes, false)
+	if err != nil {
+		cancel()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	first := h.firstEventTime(reader, container.Tty, cancel)
+
+	var last *time.Time
+	if first != nil {
+		ctx, cancel := context.WithCancel(r.Context())
+		reader, err := client.ContainerLogsTail(ctx, container.ID, 1, stdTypes, false)
+		if err != nil {
+			cancel()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		last = h.firstEventTime(reader, container.Tty, cancel)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(boundsResponse{First: first, Last: last}); err != nil {
+		log.Errorf("json encoding error while writing log bounds %v", err)
+	}
+}
+
+// firstEventTime reads the time of the first event of reader. cancel stops the reader once it is known and the rest
+// is drained.
+func (h *handler) firstEventTime(reader io.Reader, tty bool, cancel context.CancelFunc) *time.Time {
+	g := h.newEventGenerator(reader, tty)
+	defer func() {
+		cancel()
+		for range g.Events {
+		}
+	}()
+
+	event, ok := <-g.Events
+	if !ok {
+		return nil
+	}
+	timestamp := time.UnixMilli(event.Timestamp).UTC()
+	return &timestamp
+}
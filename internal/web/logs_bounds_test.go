@@ -0,0 +1,70 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_fetchLogBounds(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/bounds", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	history := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)...)
+	tail := makeMessage("2020-05-13T19:55:37.772853839Z INFO last\n", docker.STDERR)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Created: 1589396100}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, time.Unix(1589396100, 0), mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(history)), nil)
+	mockedClient.On("ContainerLogsTail", mock.Anything, id, 1, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(tail)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"first":"2020-05-13T18:55:37.772Z","last":"2020-05-13T19:55:37.772Z"}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_fetchLogBounds_no_logs(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/bounds", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, time.Time{}, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"first":null,"last":null}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_fetchLogBounds_empty_tail(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/bounds", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	history := makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(history)), nil)
+	mockedClient.On("ContainerLogsTail", mock.Anything, id, 1, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"first":"2020-05-13T18:55:37.772Z","last":null}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
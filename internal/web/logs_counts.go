@@ -0,0 +1,145 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// minCountsInterval keeps clients from asking for more buckets than a chart can use
+const minCountsInterval = 100 * time.Millisecond
+
+// logCounts is one bucket of streamLogCounts. Timestamp is the end of the bucket in milliseconds.
+type logCounts struct {
+	Timestamp int64          `json:"ts"`
+	Count     int            `json:"count"`
+	Levels    map[string]int `json:"levels,omitempty"`
+}
+
+// streamLogCounts follows the logs of a container like streamLogs but only sends how many lines arrived every
+// interval, optionally by level. Empty buckets are sent too so charts don't have gaps.
+func (h *handler) streamLogCounts(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := time.Second
+	if r.URL.Query().Has("interval") {
+		if interval, err = time.ParseDuration(r.URL.Query().Get("interval")); err != nil || interval < minCountsInterval {
+			http.Error(w, fmt.Sprintf("interval must be a duration of at least %s", minCountsInterval), http.StatusBadRequest)
+			return
+		}
+	}
+
+	byLevel := r.URL.Query().Get("byLevel") == "true"
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// only new lines are counted, the history would all land in the first bucket
+	reader, err := h.clientFromRequest(r).ContainerLogs(ctx, container.ID, "", 0, stdTypes, false)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-transform")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if h.config.SSERetry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", h.config.SSERetry.Milliseconds())
+	}
+	f.Flush()
+
+	if err == io.EOF {
+		h.writeContainerStopped(w)
+		f.Flush()
+		return
+	}
+
+	bucket := logCounts{}
+	writeBucket := func(end time.Time) {
+		bucket.Timestamp = end.UnixMilli()
+		if buf, err := json.Marshal(bucket); err != nil {
+			log.Errorf("json encoding error while streaming counts %v", err.Error())
+		} else {
+			fmt.Fprintf(w, "event: counts\ndata: %s\n\n", buf)
+		}
+		f.Flush()
+		bucket = logCounts{}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	g := h.newEventGenerator(reader, container.Tty)
+	// cancel stops docker when the stream ends early, the generator is drained so it can finish
+	defer drainEvents(g)
+	for {
+		select {
+		case event, ok := <-g.Events:
+			if !ok {
+				writeBucket(time.Now())
+				if err := <-g.Errors; err != nil && err != io.EOF && r.Context().Err() == nil {
+					log.Errorf("unknown error while streaming counts %v", err.Error())
+				}
+				h.writeContainerStopped(w)
+				f.Flush()
+				return
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			bucket.Count++
+			if byLevel {
+				if bucket.Levels == nil {
+					bucket.Levels = make(map[string]int)
+				}
+				level := event.Level
+				if level == "" {
+					level = "unknown"
+				}
+				bucket.Levels[level]++
+			}
+		case now := <-ticker.C:
+			writeBucket(now)
+		case <-h.shutdown:
+			writeServerShutdown(w)
+			f.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
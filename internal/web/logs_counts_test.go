@@ -0,0 +1,66 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_streamLogCounts(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/counts/stream?stdout=1&stderr=1&interval=1h&byLevel=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO started\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z ERROR failed\n", docker.STDERR)...)
+	data = append(data, makeMessage("2020-05-13T18:57:37.772853839Z ERROR failed again\n", docker.STDERR)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", 0, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	require.Regexp(t, `event: counts\ndata: \{"ts":\d+,"count":3,"levels":\{"error":2,"info":1\}\}\n\n`, rr.Body.String())
+	require.Contains(t, rr.Body.String(), "event: container-stopped\n")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogCounts_interval_too_short(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/counts/stream?stdout=1&interval=1ms", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "interval must be a duration of at least 100ms\n", rr.Body.String())
+}
+
+func Test_handler_streamLogCounts_disconnect(t *testing.T) {
+	id := "123456"
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, "GET", "/api/hosts/localhost/containers/"+id+"/logs/counts/stream?stdout=1&interval=1h", nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+		mockedClient.On("ContainerLogs", mock.Anything, id, "", 0, docker.STDOUT, false).Return(busyReader{ctx}, nil)
+
+		handler := createDefaultHandler(mockedClient)
+		time.AfterFunc(20*time.Millisecond, cancel)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	requireGoroutinesDone(t, before)
+}
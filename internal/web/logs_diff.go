@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDiffLines is the most distinct lines kept for each window of a diff
+const maxDiffLines = 50000
+
+// diffNormalizers replace the parts of a line that change between runs so that the rest can be compared
+var diffNormalizers = map[string]*regexp.Regexp{
+	"timestamps": regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?|\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b`),
+	// uuids and hex ids at least as long as a short git sha
+	"ids":     regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b|\b(?:0x)?[0-9a-f]{7,}\b`),
+	"numbers": regexp.MustCompile(`\d+`),
+}
+
+// diffNormalizerOrder applies timestamps before numbers, which would otherwise break timestamps apart
+var diffNormalizerOrder = []string{"timestamps", "ids", "numbers"}
+
+type diffResponse struct {
+	OnlyInA []string `json:"onlyInA"`
+	OnlyInB []string `json:"onlyInB"`
+}
+
+// diffLogs compares the lines of a container in window A (fromA, toA) to the ones in window B (fromB, toB) and
+// returns the lines only found in one of them. normalize=timestamps,ids,numbers ignores those parts of lines.
+func (h *handler) diffLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	fromA, toA, err := timeRangeFromParams(r, "fromA", "toA")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	fromB, toB, err := timeRangeFromParams(r, "fromB", "toB")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var normalizers []*regexp.Regexp
+	if value := r.URL.Query().Get("normalize"); value != "" {
+		requested := make(map[string]bool)
+		for _, name := range strings.Split(value, ",") {
+			if _, ok := diffNormalizers[name]; !ok {
+				http.Error(w, fmt.Sprintf("normalize must be a list of %s", strings.Join(diffNormalizerOrder, ", ")), http.StatusBadRequest)
+				return
+			}
+			requested[name] = true
+		}
+		for _, name := range diffNormalizerOrder {
+			if requested[name] {
+				normalizers = append(normalizers, diffNormalizers[name])
+			}
+		}
+	}
+
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	normalize := func(line string) string {
+		for _, re := range normalizers {
+			line = re.ReplaceAllString(line, "*")
+		}
+		return line
+	}
+
+	a, err := h.diffLines(r.Context(), h.clientFromRequest(r), container, fromA, toA, stdTypes, normalize)
+	if err == errDiffTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, err := h.diffLines(r.Context(), h.clientFromRequest(r), container, fromB, toB, stdTypes, normalize)
+	if err == errDiffTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := diffResponse{OnlyInA: a.missingFrom(b), OnlyInB: b.missingFrom(a)}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("json encoding error while writing diff %v", err)
+	}
+}
+
+var errDiffTooLarge = fmt.Errorf("a window has more than %d distinct lines", maxDiffLines)
+
+// diffWindow holds the distinct normalized lines of a window in the order they were first seen, with the first
+// line that normalized to each
+type diffWindow struct {
+	keys  []string
+	lines map[string]string
+}
+
+func (d diffWindow) missingFrom(other diffWindow) []string {
+	missing := []string{}
+	for _, key := range d.keys {
+		if _, ok := other.lines[key]; !ok {
+			missing = append(missing, d.lines[key])
+		}
+	}
+	return missing
+}
+
+func (h *handler) diffLines(ctx context.Context, client docker.Client, container docker.Container, from time.Time, to time.Time, stdTypes docker.StdType, normalize func(string) string) (diffWindow, error) {
+	window := diffWindow{lines: make(map[string]string)}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, err := client.ContainerLogsBetweenDates(ctx, container.ID, from, to, stdTypes, false)
+	if err != nil {
+		return window, err
+	}
+
+	g := h.newEventGenerator(reader, container.Tty)
+	var tooLarge bool
+	for event := range g.Events {
+		if tooLarge {
+			continue
+		}
+		line := messageText(event)
+		key := normalize(line)
+		if _, ok := window.lines[key]; ok {
+			continue
+		}
+		if len(window.keys) == maxDiffLines {
+			// stop docker, the rest of the generator is drained by the loop
+			tooLarge = true
+			cancel()
+			continue
+		}
+		window.keys = append(window.keys, key)
+		window.lines[key] = line
+	}
+	if tooLarge {
+		return window, errDiffTooLarge
+	}
+	// a window cut short by a failed read would show lines as missing that are not
+	if err := <-g.Errors; err != nil && err != io.EOF {
+		return window, err
+	}
+	return window, nil
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_diffLogs(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/diff?stdout=1&fromA=2020-05-13T17:00:00Z&toA=2020-05-13T18:00:00Z&fromB=2020-05-13T18:00:00Z&toB=2020-05-13T19:00:00Z&normalize=timestamps,ids", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	fromA, _ := time.Parse(time.RFC3339, "2020-05-13T17:00:00Z")
+	split, _ := time.Parse(time.RFC3339, "2020-05-13T18:00:00Z")
+	toB, _ := time.Parse(time.RFC3339, "2020-05-13T19:00:00Z")
+
+	before := append(makeMessage("2020-05-13T17:10:00.000000000Z 17:10:00 INFO request 3f9a2c1 served\n", docker.STDOUT), makeMessage("2020-05-13T17:20:00.000000000Z INFO cache warm\n", docker.STDOUT)...)
+	after := append(makeMessage("2020-05-13T18:10:00.000000000Z 18:10:00 INFO request 88be01d served\n", docker.STDOUT), makeMessage("2020-05-13T18:20:00.000000000Z ERROR cache miss\n", docker.STDOUT)...)
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, fromA, split, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(before)), nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, split, toB, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(after)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"onlyInA":["INFO cache warm"],"onlyInB":["ERROR cache miss"]}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_diffLogs_read_error(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/diff?stdout=1&fromA=2020-05-13T17:00:00Z&toA=2020-05-13T18:00:00Z&fromB=2020-05-13T18:00:00Z&toB=2020-05-13T19:00:00Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	partial := io.MultiReader(bytes.NewReader(makeMessage("2020-05-13T17:10:00.000000000Z INFO cache warm\n", docker.STDOUT)), iotest.ErrReader(errors.New("daemon restarted")))
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(partial), nil).Once()
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Equal(t, "daemon restarted\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_diffLogs_unknown_normalizer(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/diff?stdout=1&normalize=words", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "normalize must be a list of timestamps, ids, numbers\n", rr.Body.String())
+}
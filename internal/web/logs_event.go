@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxEventContext is the most lines that can be asked for on each side of an event
+const maxEventContext = 100
+
+// eventContextWindow is how far around an event the lines of its context are looked for
+const eventContextWindow = time.Minute
+
+type eventResponse struct {
+	Event  *docker.LogEvent   `json:"event"`
+	Before []*docker.LogEvent `json:"before"`
+	After  []*docker.LogEvent `json:"after"`
+}
+
+// fetchLogEvent returns the event with the id a stream sent it with so a line can be linked to.
+// context=N adds up to N lines from before and after it.
+func (h *handler) fetchLogEvent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	eventId := chi.URLParam(r, "eventId")
+
+	// ids are the timestamp in milliseconds with a -sequence for events after the first with the same timestamp
+	value, counter, _ := strings.Cut(eventId, "-")
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	sequence := 0
+	if err == nil && counter != "" {
+		sequence, err = strconv.Atoi(counter)
+	}
+	if err != nil || timestamp <= 0 || sequence < 0 {
+		http.Error(w, "eventId must be an event id", http.StatusBadRequest)
+		return
+	}
+
+	lines := 0
+	if r.URL.Query().Has("context") {
+		if lines, err = strconv.Atoi(r.URL.Query().Get("context")); err != nil || lines < 0 || lines > maxEventContext {
+			http.Error(w, "context must be between 0 and "+strconv.Itoa(maxEventContext), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	client := h.clientFromRequest(r)
+	container, err := client.FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	// the window only has to hold the millisecond of the event when no context is needed
+	from, to := time.UnixMilli(timestamp), time.UnixMilli(timestamp+1)
+	if lines > 0 {
+		from, to = from.Add(-eventContextWindow), to.Add(eventContextWindow)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reader, err := client.ContainerLogsBetweenDates(ctx, container.ID, from, to, stdTypes, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := eventResponse{Before: []*docker.LogEvent{}, After: []*docker.LogEvent{}}
+	var lastTimestamp int64
+	seen := 0
+	g := h.newEventGenerator(reader, container.Tty)
+	// the loop keeps reading after cancel so the generator is drained
+	for event := range g.Events {
+		if ctx.Err() != nil {
+			continue
+		}
+		if response.Event != nil {
+			response.After = append(response.After, event)
+			if len(response.After) == lines {
+				cancel()
+			}
+			continue
+		}
+		if event.Timestamp == lastTimestamp {
+			seen++
+		} else {
+			lastTimestamp, seen = event.Timestamp, 0
+		}
+		if event.Timestamp > timestamp {
+			cancel()
+			continue
+		}
+		if event.Timestamp == timestamp && seen == sequence {
+			response.Event = event
+			if lines == 0 {
+				cancel()
+			}
+			continue
+		}
+		if lines > 0 {
+			if len(response.Before) == lines {
+				response.Before = response.Before[1:]
+			}
+			response.Before = append(response.Before, event)
+		}
+	}
+
+	if response.Event == nil {
+		writeJSONError(w, http.StatusNotFound, errorResponse{Error: "event not found", ID: eventId})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("json encoding error while writing event %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func eventLogs() []byte {
+	data := makeMessage("2020-05-13T18:55:36.772853839Z INFO before\n", docker.STDOUT)
+	data = append(data, makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)...)
+	data = append(data, makeMessage("2020-05-13T18:55:37.772953839Z INFO same millisecond\n", docker.STDERR)...)
+	data = append(data, makeMessage("2020-05-13T18:55:38.772853839Z INFO after\n", docker.STDOUT)...)
+	return append(data, makeMessage("2020-05-13T18:55:39.772853839Z INFO later\n", docker.STDOUT)...)
+}
+
+func Test_handler_fetchLogEvent(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/events/1589396137772", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(eventLogs())), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"event":{"m":"INFO first","ts":1589396137772,"id":331122389,"l":"info","s":"stdout"},"before":[],"after":[]}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_fetchLogEvent_context(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/events/1589396137772-1?context=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(eventLogs())), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Event struct {
+			Message string `json:"m"`
+		} `json:"event"`
+		Before []struct {
+			Message string `json:"m"`
+		} `json:"before"`
+		After []struct {
+			Message string `json:"m"`
+		} `json:"after"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Equal(t, "INFO same millisecond", response.Event.Message)
+	require.Len(t, response.Before, 1)
+	require.Equal(t, "INFO first", response.Before[0].Message)
+	require.Len(t, response.After, 1)
+	require.Equal(t, "INFO after", response.After[0].Message)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_fetchLogEvent_not_found(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/events/1589396137772-5", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(eventLogs())), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.JSONEq(t, `{"error":"event not found","id":"1589396137772-5"}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_fetchLogEvent_invalid_id(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/events/first", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "eventId must be an event id\n", rr.Body.String())
+}
@@ -0,0 +1,214 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errExportAddress = errors.New("url must not point to a loopback, private or link-local address")
+
+type exportResult struct {
+	Status string `json:"status"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// exportLogs uploads the logs of a container to the pre-signed PUT url in ?url= instead of sending them to the
+// client. The file is made by downloadLogs, so every download option works the same here, and it is spooled to a
+// temp file first since object stores want a Content-Length with the upload.
+func (h *handler) exportLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	target, err := url.Parse(r.URL.Query().Get("url"))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "url must be an absolute http or https url", http.StatusBadRequest)
+		return
+	}
+
+	// checked before any logs are read, and again when connecting so a second lookup can't point somewhere else
+	allowed := exportAllowedHosts(h.config.ExportAllowedHosts)
+	if len(allowed) > 0 && !allowed[strings.ToLower(target.Hostname())] {
+		http.Error(w, "url must point to one of the allowed export hosts", http.StatusBadRequest)
+		return
+	}
+	if len(allowed) == 0 {
+		if _, err := resolvePublicIP(r.Context(), target.Hostname()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	spool, err := os.CreateTemp("", "dozzle-export-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	// the upload is a file, so it is never content encoded, buffered for a range or answered with a 304
+	download := r.Clone(r.Context())
+	query := download.URL.Query()
+	query.Del("url")
+	query.Del("buffer")
+	download.URL.RawQuery = query.Encode()
+	download.Header.Del("Accept-Encoding")
+	download.Header.Del("Range")
+	download.Header.Del("If-Modified-Since")
+
+	spooled := &spoolWriter{header: make(http.Header), file: spool}
+	h.downloadLogs(spooled, download)
+	if r.Context().Err() != nil {
+		return
+	}
+	// like net/http, a download that wrote nothing at all answers 200
+	spooled.WriteHeader(http.StatusOK)
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// errors of the download are sent as the answer to the export
+	if spooled.status != http.StatusOK {
+		for key, values := range spooled.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(spooled.status)
+		if _, err := io.Copy(w, spool); err != nil {
+			log.Debugf("error while writing export error %v", err)
+		}
+		return
+	}
+
+	upload, err := http.NewRequestWithContext(r.Context(), http.MethodPut, target.String(), spool)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upload.ContentLength = size
+	upload.Header.Set("Content-Type", spooled.header.Get("Content-Type"))
+
+	client := newExportClient(allowed)
+	defer client.CloseIdleConnections()
+	response, err := client.Do(upload)
+	if errors.Is(err, errExportAddress) {
+		http.Error(w, errExportAddress.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Warnf("export of %s failed: %v", id, err)
+		writeJSONError(w, http.StatusBadGateway, errorResponse{Error: "upload failed", ID: id})
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		log.Warnf("export of %s was rejected with %s", id, response.Status)
+		writeJSONError(w, http.StatusBadGateway, errorResponse{Error: "upload rejected with " + response.Status, ID: id})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(exportResult{Status: "uploaded", Bytes: size}); err != nil {
+		log.Errorf("json encoding error while writing export result %v", err)
+	}
+}
+
+// spoolWriter is the http.ResponseWriter an export is downloaded to, keeping the body in a file
+type spoolWriter struct {
+	header http.Header
+	status int
+	file   *os.File
+}
+
+func (s *spoolWriter) Header() http.Header {
+	return s.header
+}
+
+func (s *spoolWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
+
+func (s *spoolWriter) Write(p []byte) (int, error) {
+	s.WriteHeader(http.StatusOK)
+	return s.file.Write(p)
+}
+
+func exportAllowedHosts(hosts []string) map[string]bool {
+	allowed := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return allowed
+}
+
+// newExportClient makes the client of the upload. Without allowed hosts it only connects to public addresses so the
+// url can't reach the network Dozzle runs in. Redirects are refused since they could lead anywhere.
+func newExportClient(allowed map[string]bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if len(allowed) > 0 {
+				if !allowed[strings.ToLower(host)] {
+					return nil, errExportAddress
+				}
+				return dialer.DialContext(ctx, network, addr)
+			}
+			ip, err := resolvePublicIP(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Minute,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// resolvePublicIP looks up host and returns its first address, failing with errExportAddress if any of its
+// addresses is loopback, private, link-local or unspecified
+func resolvePublicIP(ctx context.Context, host string) (net.IP, error) {
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, address := range addresses {
+		ip := address.IP
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+			return nil, errExportAddress
+		}
+	}
+	return addresses[0].IP, nil
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_exportLogs(t *testing.T) {
+	var uploaded []byte
+	var contentLength int64
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, "application/gzip", r.Header.Get("Content-Type"))
+		contentLength = r.ContentLength
+		reader, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		uploaded, _ = io.ReadAll(reader)
+	}))
+	defer bucket.Close()
+
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?url="+url.QueryEscape(bucket.URL+"/logs.gz?signature=abc"), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("INFO Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "INFO Testing logs...\n", string(uploaded))
+	require.Positive(t, contentLength)
+	require.JSONEq(t, `{"status":"uploaded","bytes":`+strconv.FormatInt(contentLength, 10)+`}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_exportLogs_rejected(t *testing.T) {
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer bucket.Close()
+
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?url="+url.QueryEscape(bucket.URL), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadGateway, rr.Code)
+	require.JSONEq(t, `{"error":"upload rejected with 403 Forbidden","id":"123456"}`, rr.Body.String())
+}
+
+func Test_handler_exportLogs_empty(t *testing.T) {
+	var uploaded []byte
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer bucket.Close()
+
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?format=txt&url="+url.QueryEscape(bucket.URL), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, uploaded)
+	require.JSONEq(t, `{"status":"uploaded","bytes":0}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_exportLogs_download_options(t *testing.T) {
+	var uploaded []byte
+	var contentType string
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer bucket.Close()
+
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?format=txt&stripAnsi=true&url="+url.QueryEscape(bucket.URL), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("\x1b[31mERROR\x1b[0m Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil).Once()
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/plain; charset=UTF-8", contentType)
+	require.Equal(t, "ERROR Testing logs...\n", string(uploaded))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_exportLogs_download_error(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?format=xml&url="+url.QueryEscape("http://127.0.0.1/logs"), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "unsupported format: xml\n", rr.Body.String())
+}
+
+func Test_handler_exportLogs_private_address(t *testing.T) {
+	for _, target := range []string{"http://127.0.0.1/logs", "http://localhost:2375/containers", "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/logs", "http://[::1]/logs"} {
+		t.Run(target, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/123456/logs/export?url="+url.QueryEscape(target), nil)
+			require.NoError(t, err, "NewRequest should not return an error.")
+
+			mockedClient := new(MockedClient)
+			handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true})
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			require.Equal(t, "url must not point to a loopback, private or link-local address\n", rr.Body.String())
+			mockedClient.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_exportLogs_not_allowed_host(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/123456/logs/export?url="+url.QueryEscape("https://s3.amazonaws.com/bucket/logs.gz"), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createHandler(new(MockedClient), nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"minio.internal"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "url must point to one of the allowed export hosts\n", rr.Body.String())
+}
+
+func Test_handler_exportLogs_redirect(t *testing.T) {
+	followed := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer internal.Close()
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer bucket.Close()
+
+	id := "123456"
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/"+id+"/logs/export?url="+url.QueryEscape(bucket.URL), nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, EnableExport: true, ExportAllowedHosts: []string{"127.0.0.1"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadGateway, rr.Code)
+	require.JSONEq(t, `{"error":"upload rejected with 307 Temporary Redirect","id":"123456"}`, rr.Body.String())
+	require.False(t, followed)
+}
+
+func Test_handler_exportLogs_disabled(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/hosts/localhost/containers/123456/logs/export?url=https://example.com", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
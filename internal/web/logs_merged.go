@@ -0,0 +1,216 @@
+package web
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// mergedLogEvent is a log event tagged with the container it came from
+type mergedLogEvent struct {
+	*docker.LogEvent
+	ContainerID   string `json:"c"`
+	ContainerName string `json:"n"`
+}
+
+// mergeHead is the one event of a container that waits to be merged
+type mergeHead struct {
+	source int
+	event  mergedLogEvent
+}
+
+// mergeHeap orders the waiting events by timestamp and then by the order of ids
+type mergeHeap []mergeHead
+
+func (m mergeHeap) Len() int { return len(m) }
+func (m mergeHeap) Less(i, j int) bool {
+	if m[i].event.Timestamp != m[j].event.Timestamp {
+		return m[i].event.Timestamp < m[j].event.Timestamp
+	}
+	return m[i].source < m[j].source
+}
+func (m mergeHeap) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m *mergeHeap) Push(x any)   { *m = append(*m, x.(mergeHead)) }
+func (m *mergeHeap) Pop() any {
+	old := *m
+	head := old[len(old)-1]
+	*m = old[:len(old)-1]
+	return head
+}
+
+func (h *handler) streamMergedLogs(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tail := docker.DefaultTail
+	if r.URL.Query().Has("tail") {
+		tail, err = strconv.Atoi(r.URL.Query().Get("tail"))
+		if err != nil || tail < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// window=<duration> stops waiting for a quiet container after that long and sends the events of the others,
+	// which may then come before an earlier event of the quiet one
+	var window time.Duration
+	if r.URL.Query().Has("window") {
+		if window, err = time.ParseDuration(r.URL.Query().Get("window")); err != nil || window <= 0 {
+			http.Error(w, "window must be a positive duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	client := h.clientFromRequest(r)
+	containers := make([]docker.Container, 0, len(ids))
+	for _, id := range ids {
+		container, err := client.FindContainer(id)
+		if err != nil {
+			writeContainerNotFound(w, http.StatusNotFound, id, err)
+			return
+		}
+		containers = append(containers, container)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-transform")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	heads := make(chan mergeHead)
+	stopped := make(chan int)
+	next := make([]chan struct{}, len(containers))
+	running := 0
+
+	for index, container := range containers {
+		reader, err := client.ContainerLogs(ctx, container.ID, "", tail, stdTypes, false)
+		if err != nil {
+			if err != io.EOF {
+				log.Errorf("error while opening logs for %s: %v", container.ID, err)
+			}
+			fmt.Fprintf(w, "event: container-stopped\ndata: %s\n\n", container.ID)
+			continue
+		}
+
+		running++
+		next[index] = make(chan struct{}, 1)
+		g := h.newEventGenerator(reader, container.Tty)
+		// every container has at most one event waiting, the next one is only read once it was sent
+		go func(index int, container docker.Container) {
+			defer drainEvents(g)
+			for event := range g.Events {
+				if !filter.matches(event) {
+					continue
+				}
+				select {
+				case heads <- mergeHead{source: index, event: mergedLogEvent{LogEvent: event, ContainerID: container.ID, ContainerName: container.Name}}:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case <-next[index]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case stopped <- index:
+			case <-ctx.Done():
+			}
+		}(index, container)
+	}
+	f.Flush()
+
+	pending := &mergeHeap{}
+	send := func() {
+		head := heap.Pop(pending).(mergeHead)
+		if buf, err := json.Marshal(head.event); err != nil {
+			log.Errorf("json encoding error while streaming %v", err.Error())
+		} else {
+			fmt.Fprintf(w, "data: %s\n\n", buf)
+		}
+		next[head.source] <- struct{}{}
+	}
+
+	ticker := time.NewTicker(h.config.KeepAliveInterval)
+	defer ticker.Stop()
+
+	// the earliest waiting event can only be sent once every running container has one waiting, since the events
+	// of a container never get older
+	var timeout <-chan time.Time
+	for running > 0 {
+		if pending.Len() == running {
+			send()
+			f.Flush()
+			timeout = nil
+		}
+		if window > 0 && pending.Len() > 0 && timeout == nil {
+			timeout = time.After(window)
+		}
+
+		select {
+		case head := <-heads:
+			heap.Push(pending, head)
+		case index := <-stopped:
+			running--
+			log.Debugf("container stopped in merged stream: %v", containers[index].ID)
+			fmt.Fprintf(w, "event: container-stopped\ndata: %s\n\n", containers[index].ID)
+			f.Flush()
+		case <-timeout:
+			for pending.Len() > 0 {
+				send()
+			}
+			f.Flush()
+			timeout = nil
+		case <-ticker.C:
+			fmt.Fprintf(w, ":ping \n\n")
+			f.Flush()
+		case <-h.shutdown:
+			writeServerShutdown(w)
+			f.Flush()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
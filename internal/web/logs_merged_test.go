@@ -0,0 +1,156 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_streamMergedLogs(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/merged?ids=aaa,bbb&stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z INFO third\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "app"}, nil)
+	mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "db"}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "aaa", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(append(first, third...))), nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "bbb", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(second)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	require.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	require.Contains(t, body, `"m":"INFO second","ts":1589396197772`)
+	require.Contains(t, body, `"c":"bbb","n":"db"`)
+	require.Less(t, strings.Index(body, "INFO first"), strings.Index(body, "INFO second"))
+	require.Less(t, strings.Index(body, "INFO second"), strings.Index(body, "INFO third"))
+	require.Contains(t, body, "event: container-stopped\ndata: aaa\n\n")
+	require.Contains(t, body, "event: container-stopped\ndata: bbb\n\n")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamMergedLogs_waits_for_quiet_container(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		order []string
+	}{
+		// app is held back until db sent its earlier event
+		{"", []string{"INFO first", "INFO second", "INFO third"}},
+		// with a window app doesn't wait that long for db
+		{"&window=50ms", []string{"INFO first", "INFO third", "INFO second"}},
+	} {
+		t.Run("window="+tt.query, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/merged?ids=aaa,bbb&stdout=1"+tt.query, nil)
+			require.NoError(t, err, "NewRequest should not return an error.")
+
+			first := makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)
+			third := makeMessage("2020-05-13T18:57:37.772853839Z INFO third\n", docker.STDOUT)
+			second := makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)
+			quiet, writer := io.Pipe()
+			go func() {
+				time.Sleep(300 * time.Millisecond)
+				writer.Write(second)
+				writer.Close()
+			}()
+
+			mockedClient := new(MockedClient)
+			mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "app"}, nil)
+			mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb", Name: "db"}, nil)
+			mockedClient.On("ContainerLogs", mock.Anything, "aaa", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(append(first, third...))), nil)
+			mockedClient.On("ContainerLogs", mock.Anything, "bbb", "", docker.DefaultTail, docker.STDOUT, false).Return(quiet, nil)
+
+			handler := createDefaultHandler(mockedClient)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			body := rr.Body.String()
+			for i := 1; i < len(tt.order); i++ {
+				require.Less(t, strings.Index(body, tt.order[i-1]), strings.Index(body, tt.order[i]))
+			}
+			mockedClient.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_streamMergedLogs_invalid_window(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/merged?ids=aaa&stdout=1&window=soon", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "window must be a positive duration\n", rr.Body.String())
+}
+
+func Test_handler_streamMergedLogs_missing_ids(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/merged?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_handler_streamMergedLogs_server_shutdown(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/logs/merged?ids=aaa&stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa", Name: "app"}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "aaa", "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	shutdown := make(chan struct{})
+	close(shutdown)
+	handler := createRouter(&handler{
+		clients:  map[string]docker.Client{"localhost": mockedClient},
+		config:   &Config{Base: "/", KeepAliveInterval: time.Minute, Authorization: Authorization{Provider: NONE}},
+		shutdown: shutdown,
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "event: server-shutdown\ndata: server is shutting down\n\n")
+	require.NotContains(t, rr.Body.String(), "container-stopped")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamMergedLogs_disconnect(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, "GET", "/api/hosts/localhost/logs/merged?ids=aaa,bbb&stdout=1", nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		mockedClient.On("FindContainer", "aaa").Return(docker.Container{ID: "aaa"}, nil)
+		mockedClient.On("FindContainer", "bbb").Return(docker.Container{ID: "bbb"}, nil)
+		mockedClient.On("ContainerLogs", mock.Anything, "aaa", "", docker.DefaultTail, docker.STDOUT, false).Return(busyReader{ctx}, nil)
+		mockedClient.On("ContainerLogs", mock.Anything, "bbb", "", docker.DefaultTail, docker.STDOUT, false).Return(busyReader{ctx}, nil)
+
+		handler := createDefaultHandler(mockedClient)
+		time.AfterFunc(20*time.Millisecond, cancel)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	requireGoroutinesDone(t, before)
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// streamLogsNDJSON follows the logs like streamLogs but writes one JSON event per line without SSE framing
+func (h *handler) streamLogsNDJSON(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tail := docker.DefaultTail
+	if r.URL.Query().Has("tail") {
+		tail, err = strconv.Atoi(r.URL.Query().Get("tail"))
+		if err != nil || tail < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reader, err := h.clientFromRequest(r).ContainerLogs(ctx, container.ID, "", tail, stdTypes, false)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	if err == io.EOF {
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	g := h.newEventGenerator(reader, container.Tty)
+loop:
+	for {
+		select {
+		case event, ok := <-g.Events:
+			if !ok {
+				break loop
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if err := encoder.Encode(event); err != nil {
+				log.Errorf("json encoding error while streaming %v", err.Error())
+				continue
+			}
+			f.Flush()
+		case <-h.shutdown:
+			// a line without "m" so clients can tell it apart from the logs
+			if err := encoder.Encode(serverShutdown); err != nil {
+				log.Errorf("json encoding error while streaming %v", err.Error())
+			}
+			f.Flush()
+			// stop docker and drain the generator so it can finish
+			cancel()
+			go func() {
+				for range g.Events {
+				}
+			}()
+			return
+		}
+	}
+
+	select {
+	case err := <-g.Errors:
+		if err != nil && err != io.EOF && r.Context().Err() == nil {
+			log.Errorf("unknown error while streaming %v", err.Error())
+		}
+	default:
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/beme/abide"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_streamLogsNDJSON(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream.ndjson?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO Testing more logs...\n", docker.STDOUT)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogsNDJSON_strict_std_types(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/stream.ndjson", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createHandler(new(MockedClient), nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StrictStdTypes: true})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_handler_streamLogsNDJSON_server_shutdown(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream.ndjson?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	shutdown := make(chan struct{})
+	close(shutdown)
+	handler := createRouter(&handler{
+		clients:  map[string]docker.Client{"localhost": mockedClient},
+		config:   &Config{Base: "/", KeepAliveInterval: time.Minute, Authorization: Authorization{Provider: NONE}},
+		shutdown: shutdown,
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, `{"event":"server-shutdown","data":"server is shutting down"}`+"\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPollLimit   = 100
+	maxPollLimit       = 1000
+	defaultPollTimeout = 25 * time.Second
+	maxPollTimeout     = time.Minute
+)
+
+// pollIdle is how long a poll that already has events waits for more before returning
+var pollIdle = 100 * time.Millisecond
+
+type pollResponse struct {
+	Events      []*docker.LogEvent `json:"events"`
+	LastEventId string             `json:"lastEventId"`
+	// Ended is set when the logs of the container ended, polling again only returns new logs once it restarts
+	Ended bool `json:"ended,omitempty"`
+}
+
+// pollLogs is a long-polling fallback for networks where SSE and WebSockets don't work. It waits up to timeout
+// for events after lastEventId and returns them with the id to send on the next poll.
+func (h *handler) pollLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultPollLimit
+	if r.URL.Query().Has("limit") {
+		if limit, err = strconv.Atoi(r.URL.Query().Get("limit")); err != nil || limit <= 0 || limit > maxPollLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPollLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	timeout := defaultPollTimeout
+	if r.URL.Query().Has("timeout") {
+		if timeout, err = time.ParseDuration(r.URL.Query().Get("timeout")); err != nil || timeout <= 0 || timeout > maxPollTimeout {
+			http.Error(w, fmt.Sprintf("timeout must be a duration of at most %s", maxPollTimeout), http.StatusBadRequest)
+			return
+		}
+	}
+
+	container, err := h.clientFromRequest(r).FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	lastEventId := r.URL.Query().Get("lastEventId")
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	response := pollResponse{Events: []*docker.LogEvent{}, LastEventId: lastEventId}
+
+	// ids are made and resumed like the ones of streamLogs, the events of the last id the client already has are
+	// skipped
+	since, resumeTimestamp, skip := resumeFromEventId(lastEventId)
+	lastTimestamp, sequence := resumeTimestamp, skip-1
+	if skip == 0 {
+		sequence = 0
+	}
+
+	reader, err := h.clientFromRequest(r).ContainerLogs(ctx, container.ID, since, docker.DefaultTail, stdTypes, false)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err == io.EOF {
+		response.Ended = true
+	} else {
+		g := h.newEventGenerator(reader, container.Tty)
+		idle := time.NewTimer(timeout)
+		defer idle.Stop()
+
+	collect:
+		for len(response.Events) < limit {
+			select {
+			case event, ok := <-g.Events:
+				if !ok {
+					response.Ended = ctx.Err() == nil
+					break collect
+				}
+				if !filter.matches(event) {
+					continue
+				}
+				if skip > 0 {
+					if event.Timestamp == resumeTimestamp {
+						skip--
+						continue
+					}
+					skip = 0
+				}
+				response.Events = append(response.Events, event)
+				if event.Timestamp > 0 && event.Timestamp != lastTimestamp {
+					lastTimestamp, sequence = event.Timestamp, 0
+					response.LastEventId = strconv.FormatInt(event.Timestamp, 10)
+				} else {
+					sequence++
+					response.LastEventId = fmt.Sprintf("%d-%d", lastTimestamp, sequence)
+				}
+				if !idle.Stop() {
+					<-idle.C
+				}
+				idle.Reset(pollIdle)
+			case <-idle.C:
+				break collect
+			case <-ctx.Done():
+				break collect
+			case <-h.shutdown:
+				break collect
+			}
+		}
+		// stop docker and drain the generator so it can finish
+		cancel()
+		go func() {
+			for range g.Events {
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("json encoding error while writing poll %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_pollLogs(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/poll?stdout=1&lastEventId=1589396137000", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396136999", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{
+		"events": [
+			{"m":"INFO first","ts":1589396137772,"id":331122389,"l":"info","s":"stdout"},
+			{"m":"INFO second","ts":1589396197772,"id":436554938,"l":"info","s":"stdout"}
+		],
+		"lastEventId": "1589396197772",
+		"ended": true
+	}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_pollLogs_same_timestamp(t *testing.T) {
+	id := "123456"
+	var data []byte
+	for _, line := range []string{"first", "second", "third"} {
+		data = append(data, makeMessage("2020-05-13T18:55:37.772853839Z INFO "+line+"\n", docker.STDOUT)...)
+	}
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil).Once()
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil).Once()
+	handler := createDefaultHandler(mockedClient)
+
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/poll?stdout=1&limit=2", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"lastEventId":"1589396137772-1"`)
+	require.Contains(t, rr.Body.String(), "second")
+
+	// the poll resumes from the millisecond of the last id and only returns the event after the two it had
+	req, err = http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/poll?stdout=1&lastEventId=1589396137772-1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{
+		"events": [
+			{"m":"INFO third","ts":1589396137772,"id":2019627200,"l":"info","s":"stdout"}
+		],
+		"lastEventId": "1589396137772-2",
+		"ended": true
+	}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_pollLogs_limit(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/poll?stdout=1&limit=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"lastEventId":"1589396137772"`)
+	require.NotContains(t, rr.Body.String(), "second")
+	require.NotContains(t, rr.Body.String(), "ended")
+}
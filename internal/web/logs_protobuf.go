@@ -0,0 +1,128 @@
+package web
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative log_event.proto
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const protobufContentType = "application/x-protobuf"
+
+// acceptsProtobuf reports whether the client asked for events as length-delimited protocol buffers
+func acceptsProtobuf(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(value, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), protobufContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// protobufWriter is the streamWriter of clients that accept protocol buffers. It writes every event, named event
+// and ping as a length-delimited Frame of log_event.proto.
+type protobufWriter struct {
+	w      io.Writer
+	buffer []byte
+	failed error
+}
+
+func (p *protobufWriter) write(frame *Frame) int {
+	if p.failed != nil {
+		return 0
+	}
+	message, err := proto.MarshalOptions{Deterministic: true}.Marshal(frame)
+	if err != nil {
+		log.Errorf("protobuf encoding error while streaming %v", err.Error())
+		return 0
+	}
+	p.buffer = protowire.AppendBytes(p.buffer[:0], message)
+	_, p.failed = p.w.Write(p.buffer)
+	return len(message)
+}
+
+func (p *protobufWriter) setHeaders(header http.Header) {
+	header.Set("Content-Type", protobufContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+}
+
+func (p *protobufWriter) retry(time.Duration) {}
+
+func (p *protobufWriter) event(event *docker.LogEvent, matches [][]int, id string) int {
+	message, err := newLogEventMessage(event, matches, id)
+	if err != nil {
+		log.Errorf("json encoding error while streaming %v", err.Error())
+		return 0
+	}
+	return p.write(&Frame{Frame: &Frame_Event{Event: message}})
+}
+
+func (p *protobufWriter) control(name string, data string) {
+	p.write(&Frame{Frame: &Frame_Control{Control: &Control{Event: name, Data: data}}})
+}
+
+func (p *protobufWriter) stopped(time.Duration) {
+	p.control("container-stopped", "end of stream")
+}
+
+func (p *protobufWriter) ping() {
+	p.control("ping", "")
+}
+
+func (p *protobufWriter) err() error {
+	return p.failed
+}
+
+// newLogEventMessage converts an event to the LogEvent message, with messages and fields that aren't plain text
+// encoded as JSON
+func newLogEventMessage(event *docker.LogEvent, matches [][]int, id string) (*LogEvent, error) {
+	message := &LogEvent{
+		Timestamp:  event.Timestamp,
+		Id:         event.Id,
+		Level:      event.Level,
+		Position:   string(event.Position),
+		Stream:     event.Stream,
+		Truncated:  event.Truncated,
+		Seq:        event.Seq,
+		Repeated:   int32(event.Repeated),
+		Time:       event.Time,
+		Details:    event.Details,
+		StreamType: uint32(event.StreamType),
+		Sampled:    event.Sampled,
+		Skipped:    int32(event.Skipped),
+		EventId:    id,
+	}
+	switch text := event.Message.(type) {
+	case nil:
+	case string:
+		message.Message = &LogEvent_Text{Text: text}
+	default:
+		data, err := json.Marshal(text)
+		if err != nil {
+			return nil, err
+		}
+		message.Message = &LogEvent_Json{Json: string(data)}
+	}
+	if len(event.Fields) > 0 {
+		data, err := json.Marshal(event.Fields)
+		if err != nil {
+			return nil, err
+		}
+		message.Fields = string(data)
+	}
+	for _, match := range matches {
+		message.Matches = append(message.Matches, &Match{Start: int32(match[0]), End: int32(match[1])})
+	}
+	return message, nil
+}
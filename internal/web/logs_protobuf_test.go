@@ -0,0 +1,260 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// readFrames decodes a body of length-delimited frames
+func readFrames(t *testing.T, body []byte) []*Frame {
+	var frames []*Frame
+	reader := bufio.NewReader(bytes.NewReader(body))
+	for {
+		frame := &Frame{}
+		err := protodelim.UnmarshalFrom(reader, frame)
+		if err == io.EOF {
+			return frames
+		}
+		require.NoError(t, err, "Expected a length-delimited frame")
+		frames = append(frames, frame)
+	}
+}
+
+func Test_handler_streamLogs_protobuf(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:55:38.772853839Z {\"msg\":\"second\"}\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/x-protobuf", rr.Header().Get("Content-Type"))
+
+	frames := readFrames(t, rr.Body.Bytes())
+	require.Len(t, frames, 4)
+	require.Equal(t, "container-info", frames[0].GetControl().GetEvent())
+	require.Contains(t, frames[0].GetControl().GetData(), `"id":"123456"`)
+
+	first := frames[1].GetEvent()
+	require.Equal(t, "INFO first", first.GetText())
+	require.Equal(t, "info", first.GetLevel())
+	require.Equal(t, "stdout", first.GetStream())
+	require.Equal(t, int64(1589396137772), first.GetTimestamp())
+	require.Equal(t, "1589396137772", first.GetEventId())
+	require.Equal(t, int64(1), first.GetSeq())
+
+	second := frames[2].GetEvent()
+	require.Equal(t, `{"msg":"second"}`, second.GetJson())
+	require.Equal(t, int64(2), second.GetSeq())
+
+	require.Equal(t, "container-stopped", frames[3].GetControl().GetEvent())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_protobuf_resume(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept", "application/x-protobuf")
+	req.Header.Set("Last-Event-ID", "1589396137772")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z first\n", docker.STDOUT), makeMessage("2020-05-13T18:55:38.772853839Z second\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var texts []string
+	for _, frame := range readFrames(t, rr.Body.Bytes()) {
+		if event := frame.GetEvent(); event != nil {
+			texts = append(texts, event.GetText())
+		}
+	}
+	require.Equal(t, []string{"second"}, texts, "Expected the event of the last event id to be skipped")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_protobuf_server_shutdown(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	shutdown := make(chan struct{})
+	close(shutdown)
+	handler := createRouter(&handler{
+		clients:  map[string]docker.Client{"localhost": mockedClient},
+		config:   &Config{Base: "/", KeepAliveInterval: time.Minute, Authorization: Authorization{Provider: NONE}},
+		shutdown: shutdown,
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	frames := readFrames(t, rr.Body.Bytes())
+	require.NotEmpty(t, frames)
+	last := frames[len(frames)-1].GetControl()
+	require.Equal(t, "server-shutdown", last.GetEvent())
+	require.Equal(t, "server is shutting down", last.GetData())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_protobuf_fields(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/stream?stdout=1&fields=m", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// failingWriter fails every write to the body like a client that went away
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (f failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func Test_handler_streamLogs_protobuf_write_error(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(failingWriter{httptest.NewRecorder()}, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end after a failed write")
+	}
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_newLogEventMessage(t *testing.T) {
+	event := &docker.LogEvent{
+		Message:   map[string]any{"msg": "hello"},
+		Timestamp: 1,
+		Fields:    map[string]any{"level": "info"},
+		Details:   map[string]string{"b": "2", "a": "1"},
+	}
+	message, err := newLogEventMessage(event, [][]int{{2, 4}}, "1-1")
+	require.NoError(t, err)
+	require.Equal(t, `{"msg":"hello"}`, message.GetJson())
+	require.Equal(t, `{"level":"info"}`, message.GetFields())
+	require.Equal(t, map[string]string{"a": "1", "b": "2"}, message.GetDetails())
+	require.Equal(t, "1-1", message.GetEventId())
+	require.Len(t, message.GetMatches(), 1)
+	require.Equal(t, int32(2), message.GetMatches()[0].GetStart())
+	require.Equal(t, int32(4), message.GetMatches()[0].GetEnd())
+}
+
+// Test_logEventProto checks that log_event.pb.go was generated from the log_event.proto next to it and that a frame
+// decodes with nothing but the descriptor, like a consumer that generated its own decoder would
+func Test_logEventProto(t *testing.T) {
+	source, err := os.ReadFile("log_event.proto")
+	require.NoError(t, err)
+
+	declared := make(map[string]map[string]int)
+	var message string
+	messagePattern := regexp.MustCompile(`^message (\w+) \{$`)
+	fieldPattern := regexp.MustCompile(`^\s+(?:repeated )?(?:map<\w+, \w+>|\w+) (\w+) = (\d+);$`)
+	for _, line := range bytes.Split(source, []byte("\n")) {
+		if m := messagePattern.FindSubmatch(line); m != nil {
+			message = string(m[1])
+			declared[message] = make(map[string]int)
+		} else if m := fieldPattern.FindSubmatch(line); m != nil {
+			number, _ := strconv.Atoi(string(m[2]))
+			declared[message][string(m[1])] = number
+		}
+	}
+
+	messages := File_log_event_proto.Messages()
+	generated := make(map[string]map[string]int)
+	for i := 0; i < messages.Len(); i++ {
+		fields := messages.Get(i).Fields()
+		generated[string(messages.Get(i).Name())] = make(map[string]int)
+		for j := 0; j < fields.Len(); j++ {
+			generated[string(messages.Get(i).Name())][string(fields.Get(j).Name())] = int(fields.Get(j).Number())
+		}
+	}
+	require.Equal(t, declared, generated, "Expected log_event.pb.go to be generated from log_event.proto")
+
+	event, err := newLogEventMessage(&docker.LogEvent{Message: "hello", Timestamp: 1589396137772, Details: map[string]string{"tag": "web"}}, [][]int{{0, 5}}, "1589396137772")
+	require.NoError(t, err)
+	encoded, err := proto.Marshal(&Frame{Frame: &Frame_Event{Event: event}})
+	require.NoError(t, err)
+
+	frame := dynamicpb.NewMessage(File_log_event_proto.Messages().ByName("Frame"))
+	require.NoError(t, proto.Unmarshal(encoded, frame))
+	decoded := frame.Get(frame.Descriptor().Fields().ByName("event")).Message()
+	fields := decoded.Descriptor().Fields()
+	require.Equal(t, "hello", decoded.Get(fields.ByName("text")).String())
+	require.Equal(t, int64(1589396137772), decoded.Get(fields.ByName("timestamp")).Int())
+	require.Equal(t, "1589396137772", decoded.Get(fields.ByName("event_id")).String())
+	require.Equal(t, "web", decoded.Get(fields.ByName("details")).Map().Get(protoreflect.ValueOfString("tag").MapKey()).String())
+}
+
+func Test_acceptsProtobuf(t *testing.T) {
+	tests := map[string]bool{
+		"":                                      false,
+		"text/event-stream":                     false,
+		"application/x-protobuf":                true,
+		"text/html, application/x-protobuf;q=1": true,
+	}
+	for header, expected := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", header)
+		require.Equal(t, expected, acceptsProtobuf(req), header)
+	}
+}
@@ -2,13 +2,19 @@ package web
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"runtime"
 	"time"
 
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -16,6 +22,9 @@ import (
 	"github.com/beme/abide"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func Test_handler_streamLogs_happy(t *testing.T) {
@@ -33,7 +42,7 @@ func Test_handler_streamLogs_happy(t *testing.T) {
 	data := makeMessage("INFO Testing logs...", docker.STDOUT)
 
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: false}, nil)
-	mockedClient.On("ContainerLogs", mock.Anything, mock.Anything, "", docker.STDALL).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("ContainerLogs", mock.Anything, mock.Anything, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
 
 	handler := createDefaultHandler(mockedClient)
 	rr := httptest.NewRecorder()
@@ -57,7 +66,312 @@ func Test_handler_streamLogs_happy_with_id(t *testing.T) {
 	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...", docker.STDOUT)
 
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
-	mockedClient.On("ContainerLogs", mock.Anything, mock.Anything, "", docker.STDALL).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("ContainerLogs", mock.Anything, mock.Anything, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_tail(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	q := req.URL.Query()
+	q.Add("stdout", "true")
+	q.Add("stderr", "true")
+	q.Add("tail", "0")
+
+	req.URL.RawQuery = q.Encode()
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", 0, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_synthetic_id(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	q := req.URL.Query()
+	q.Add("stdout", "true")
+	q.Add("stderr", "true")
+
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Last-Event-ID", "1589396137772-2")
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	second := makeMessage("no timestamp here\n", docker.STDOUT)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_same_timestamp(t *testing.T) {
+	id := "123456"
+	burst := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT), makeMessage("2020-05-13T18:55:37.772900000Z INFO second\n", docker.STDOUT)...)
+	burst = append(burst, makeMessage("2020-05-13T18:55:37.772950000Z INFO third\n", docker.STDOUT)...)
+
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(burst)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "id: 1589396137772\n\n")
+	require.Contains(t, rr.Body.String(), "id: 1589396137772-1\n\n")
+	require.Contains(t, rr.Body.String(), "id: 1589396137772-2\n\n")
+
+	// resuming in the middle of the burst only sends the rest of it
+	req, err = http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Last-Event-ID", "1589396137772-1")
+
+	mockedClient = new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(burst)), nil)
+
+	handler = createDefaultHandler(mockedClient)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.NotContains(t, rr.Body.String(), "INFO first")
+	require.NotContains(t, rr.Body.String(), "INFO second")
+	require.Contains(t, rr.Body.String(), "\"m\":\"INFO third\"")
+	require.Contains(t, rr.Body.String(), "id: 1589396137772-2\n\n")
+	mockedClient.AssertExpectations(t)
+}
+
+// sseEvents returns the seq and id of every log event in an SSE body in order
+func sseEvents(t *testing.T, body string) (seqs []int64, ids []string) {
+	for _, message := range strings.Split(body, "\n\n") {
+		var data, id string
+		for _, line := range strings.Split(message, "\n") {
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data = value
+			}
+			if value, ok := strings.CutPrefix(line, "id: "); ok {
+				id = value
+			}
+		}
+		if id == "" {
+			continue
+		}
+		var event docker.LogEvent
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		seqs, ids = append(seqs, event.Seq), append(ids, id)
+	}
+	return seqs, ids
+}
+
+func Test_handler_streamLogs_seq(t *testing.T) {
+	id := "123456"
+	var burst []byte
+	for _, line := range []string{"first", "second", "third", "fourth"} {
+		burst = append(burst, makeMessage("2020-05-13T18:55:37.772853839Z INFO "+line+"\n", docker.STDOUT)...)
+	}
+	burst = append(burst, makeMessage("2020-05-13T18:55:38.000000000Z INFO later\n", docker.STDOUT)...)
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(burst)), nil).Once()
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(burst)), nil).Once()
+	handler := createDefaultHandler(mockedClient)
+
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	seqs, ids := sseEvents(t, rr.Body.String())
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, seqs)
+	require.Equal(t, []string{"1589396137772", "1589396137772-1", "1589396137772-2", "1589396137772-3", "1589396138000"}, ids)
+
+	// the client saw the first two events of the millisecond, the new connection skips exactly those and starts seq over
+	req, err = http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Last-Event-ID", "1589396137772-1")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	seqs, ids = sseEvents(t, rr.Body.String())
+	require.Equal(t, []int64{1, 2, 3}, seqs)
+	require.Equal(t, []string{"1589396137772-2", "1589396137772-3", "1589396138000"}, ids)
+	require.NotContains(t, rr.Body.String(), "INFO first")
+	require.NotContains(t, rr.Body.String(), "INFO second")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_read_timeout(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z INFO before the daemon hangs\n", docker.STDOUT))
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, KeepAliveInterval: time.Hour, StreamReadTimeout: 200 * time.Millisecond})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "INFO before the daemon hangs")
+	require.True(t, strings.HasSuffix(rr.Body.String(), "event: read-timeout\ndata: 200ms\n\n"))
+	mockedClient.AssertExpectations(t)
+}
+
+// statsClient sends one stat and waits like a container that keeps running
+type statsClient struct {
+	*MockedClient
+	stat docker.ContainerStat
+}
+
+func (c *statsClient) ContainerStats(ctx context.Context, id string, stats chan<- docker.ContainerStat) error {
+	stats <- c.stat
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func Test_handler_streamLogs_stats(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&stats=true&statsInterval=20ms", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	minStatsInterval = time.Millisecond
+	defer func() { minStatsInterval = time.Second }()
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z INFO under load\n", docker.STDOUT))
+		time.Sleep(200 * time.Millisecond)
+		writer.Close()
+	}()
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	client := &statsClient{MockedClient: mockedClient, stat: docker.ContainerStat{ID: id, CPUPercent: 12.5, MemoryPercent: 3, MemoryUsage: 1024}}
+	handler := createDefaultHandler(client)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "INFO under load")
+	require.Equal(t, 1, strings.Count(rr.Body.String(), "event: stats\n"), "Expected a stat to be sent once")
+	require.Contains(t, rr.Body.String(), "event: stats\ndata: {\"id\":\"123456\",\"cpu\":12.5,\"memory\":3,\"memoryUsage\":1024}\n\n")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_max_duration(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	q := req.URL.Query()
+	q.Add("stdout", "true")
+	q.Add("stderr", "true")
+	q.Add("maxDuration", "100ms")
+
+	req.URL.RawQuery = q.Encode()
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT))
+		time.Sleep(500 * time.Millisecond)
+		writer.CloseWithError(context.Canceled)
+	}()
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDALL, false).Return(reader, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_debug_stats(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&debugStats=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		writer.Close()
+	}()
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, KeepAliveInterval: 50 * time.Millisecond})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "event: debug-stats\ndata: {")
+	require.Contains(t, rr.Body.String(), `"routines":`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_reconnect(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	reconnectBackoff = time.Millisecond
+	defer func() { reconnectBackoff = time.Second }()
+
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z INFO before restart\n", docker.STDOUT))
+		writer.CloseWithError(errors.New("daemon restarted"))
+	}()
+	resumed := makeMessage("2020-05-13T18:56:37.772853839Z INFO after restart\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil).Once()
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(resumed)), nil).Once()
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StreamRetries: 2})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_container_info(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	started := time.Date(2020, 5, 13, 18, 0, 0, 0, time.UTC)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: true, Started: started}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
 
 	handler := createDefaultHandler(mockedClient)
 	rr := httptest.NewRecorder()
@@ -66,6 +380,23 @@ func Test_handler_streamLogs_happy_with_id(t *testing.T) {
 	mockedClient.AssertExpectations(t)
 }
 
+func Test_handler_streamLogs_container_info_labels(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	labels := map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web", "secret": "value"}
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Labels: labels}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StreamLabels: []string{"com.docker.compose.project", "missing"}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
 func Test_handler_streamLogs_happy_container_stopped(t *testing.T) {
 	id := "123456"
 	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
@@ -78,7 +409,7 @@ func Test_handler_streamLogs_happy_container_stopped(t *testing.T) {
 
 	mockedClient := new(MockedClient)
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
-	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.STDALL).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
 
 	handler := createDefaultHandler(mockedClient)
 	rr := httptest.NewRecorder()
@@ -119,7 +450,7 @@ func Test_handler_streamLogs_error_reading(t *testing.T) {
 
 	mockedClient := new(MockedClient)
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
-	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.STDALL).Return(io.NopCloser(strings.NewReader("")), errors.New("test error"))
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(strings.NewReader("")), errors.New("test error"))
 
 	handler := createDefaultHandler(mockedClient)
 	rr := httptest.NewRecorder()
@@ -136,13 +467,49 @@ func Test_handler_streamLogs_error_std(t *testing.T) {
 
 	mockedClient := new(MockedClient)
 
-	handler := createDefaultHandler(mockedClient)
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StrictStdTypes: true})
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
 	mockedClient.AssertExpectations(t)
 }
 
+func Test_handler_streamLogs_default_std_types(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, DefaultStdTypes: docker.STDOUT})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "INFO Testing logs...")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_all_std_types(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?from=2020-05-13T18:55:37Z&to=2020-05-13T18:56:37Z", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDERR)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "INFO Testing logs...")
+	mockedClient.AssertExpectations(t)
+}
+
 func Test_handler_between_dates(t *testing.T) {
 	id := "123456"
 	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs", nil)
@@ -165,7 +532,7 @@ func Test_handler_between_dates(t *testing.T) {
 	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO Testing stderr logs...\n", docker.STDERR)
 	data := append(first, second...)
 
-	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, from, to, docker.STDALL).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, from, to, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
 	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
 
 	handler := createDefaultHandler(mockedClient)
@@ -175,11 +542,1282 @@ func Test_handler_between_dates(t *testing.T) {
 	mockedClient.AssertExpectations(t)
 }
 
-func makeMessage(message string, stream docker.StdType) []byte {
-	data := make([]byte, 8)
-	binary.BigEndian.PutUint32(data[4:], uint32(len(message)))
-	data[0] = byte(stream / 2)
-	data = append(data, []byte(message)...)
+func Test_handler_between_dates_relative(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&from=-1h&to=-30m", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
 
-	return data
+	now := time.Now()
+	near := func(expected time.Time) any {
+		return mock.MatchedBy(func(actual time.Time) bool {
+			return actual.Sub(expected).Abs() < time.Minute
+		})
+	}
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, near(now.Add(-time.Hour)), near(now.Add(-30*time.Minute)), docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_gzip(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Accept-Encoding", "deflate, gzip")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"m":"INFO Testing stdout logs..."`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_acceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"br", false},
+		{"gzip;q=0", false},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", test.header)
+		require.Equal(t, test.expected, acceptsGzip(req), test.header)
+	}
+}
+
+func Test_handler_streamLogs_max_line_length(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&maxLineLength=10", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO short\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO something much longer\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_parse_json(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&parseJson=true&prettyJson=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z {\"level\":\"info\",\"msg\":\"started\"}\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO plain text\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_fields(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&fields=timestamp,message,unknown", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO started\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "data: {\"m\":\"INFO started\",\"ts\":1589396137772}\n")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_join_stack_traces(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stderr=1&joinStackTraces=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z ERROR request failed\n", docker.STDERR)
+	data = append(data, makeMessage("2020-05-13T18:55:37.772853839Z \tat com.example.Handler.run(Handler.java:42)\n", docker.STDERR)...)
+	data = append(data, makeMessage("2020-05-13T18:55:38.772853839Z INFO recovered\n", docker.STDERR)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDERR, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), `"m":"ERROR request failed\n\tat com.example.Handler.run(Handler.java:42)"`)
+	require.Contains(t, rr.Body.String(), `"m":"INFO recovered"`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_join_stack_traces_max_duration(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stderr=1&joinStackTraces=true&maxDuration=100ms&noPing=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	stackTraceTimeout = time.Minute
+	defer func() { stackTraceTimeout = 500 * time.Millisecond }()
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z ERROR request failed\n", docker.STDERR))
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z \tat com.example.Handler.run(Handler.java:42)\n", docker.STDERR))
+		time.Sleep(300 * time.Millisecond)
+		writer.CloseWithError(context.Canceled)
+	}()
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDERR, false).Return(reader, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	body := rr.Body.String()
+	trace := `"m":"ERROR request failed\n\tat com.example.Handler.run(Handler.java:42)"`
+	require.Contains(t, body, trace, "Expected the held back trace to be sent before the stream ended")
+	require.Less(t, strings.Index(body, trace), strings.Index(body, "event: stream-timeout"))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_eventFieldNames(t *testing.T) {
+	require.Equal(t, "m", eventFieldNames["message"])
+	require.Equal(t, "m", eventFieldNames["m"])
+	require.Equal(t, "ts", eventFieldNames["timestamp"])
+	require.Equal(t, "streamType", eventFieldNames["streamType"])
+	require.Equal(t, "matches", eventFieldNames["matches"])
+	require.NotContains(t, eventFieldNames, "logEvent")
+	require.Len(t, eventFieldNames, 21, "Expected a name and key for every field of docker.LogEvent and matches")
+}
+
+func Test_filterEventFields(t *testing.T) {
+	event := highlightedLogEvent{LogEvent: &docker.LogEvent{Message: "hello", Level: "info"}, Matches: [][]int{{0, 5}}}
+	fields := filterEventFields(event, map[string]bool{"m": true, "ts": true, "s": true, "matches": true})
+	require.Equal(t, map[string]any{"m": "hello", "ts": int64(0), "matches": [][]int{{0, 5}}}, fields, "Expected empty values with omitempty to be left out")
+}
+
+func Test_extractJsonFields(t *testing.T) {
+	event := &docker.LogEvent{Message: map[string]interface{}{"msg": "hello"}}
+	extractJsonFields(event, false)
+	require.Equal(t, `{"msg":"hello"}`, event.Message)
+	require.Equal(t, map[string]any{"msg": "hello"}, event.Fields)
+
+	event = &docker.LogEvent{Message: `{"msg":"hello"}`}
+	extractJsonFields(event, true)
+	require.Equal(t, "{\n  \"msg\": \"hello\"\n}", event.Message)
+	require.Equal(t, map[string]any{"msg": "hello"}, event.Fields)
+
+	for _, message := range []string{"plain text", "[1,2]", "null"} {
+		event = &docker.LogEvent{Message: message}
+		extractJsonFields(event, false)
+		require.Equal(t, message, event.Message)
+		require.Nil(t, event.Fields)
+	}
+}
+
+func Test_handler_streamLogs_server_shutdown(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	shutdown := make(chan struct{})
+	close(shutdown)
+	handler := createRouter(&handler{
+		clients:  map[string]docker.Client{"localhost": mockedClient},
+		config:   &Config{Base: "/", KeepAliveInterval: time.Minute, Authorization: Authorization{Provider: NONE}},
+		shutdown: shutdown,
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "event: server-shutdown\ndata: server is shutting down\n\n")
+	require.NotContains(t, rr.Body.String(), "container-stopped")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_container_ended(t *testing.T) {
+	tests := []struct {
+		state    string
+		expected string
+	}{
+		{"exited", "event: container-exited\ndata: {\"state\":\"exited\",\"exitCode\":1}\n\n"},
+		{"dead", "event: container-dead\ndata: {\"state\":\"dead\",\"exitCode\":1}\n\n"},
+		{"restarting", "event: container-restarting\ndata: {\"state\":\"restarting\"}\n\n"},
+		{"running", "event: container-stopped\ndata: end of stream\n\n"},
+	}
+
+	for _, test := range tests {
+		id := "123456"
+		req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		data := makeMessage("2020-05-13T18:55:37.772853839Z INFO last words\n", docker.STDOUT)
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, State: "running"}, nil).Once()
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, State: test.state, ExitCode: 1}, nil).Once()
+		mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+		handler := createDefaultHandler(mockedClient)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.True(t, strings.HasSuffix(rr.Body.String(), test.expected), test.state)
+		mockedClient.AssertExpectations(t)
+	}
+}
+
+func Test_handler_streamLogs_unsupported_log_driver(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, LogDriver: "awslogs"}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), errors.New("Error response from daemon: configured logging driver does not support reading"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, strings.HasSuffix(rr.Body.String(), "event: unsupported-log-driver\ndata: {\"driver\":\"awslogs\"}\n\n"))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_tz(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&tz=UTC", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_summary(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO one\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO two\n", docker.STDOUT)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var summary *log.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "log stream summary" {
+			summary = entry
+		}
+	}
+	require.NotNil(t, summary)
+	require.Equal(t, int64(2), summary.Data["events"])
+	require.Equal(t, id, summary.Data["id"])
+	require.Contains(t, summary.Data, "bytes")
+	require.Contains(t, summary.Data, "duration")
+}
+
+func Test_handler_streamLogs_raw_stream(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&stderr=1&rawStream=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := append(makeMessage("2020-05-13T18:55:37.772853839Z INFO out\n", docker.STDOUT), makeMessage("2020-05-13T18:56:37.772853839Z INFO err\n", docker.STDERR)...)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), `"s":"stdout","seq":1,"streamType":2}`)
+	require.Contains(t, rr.Body.String(), `"s":"stderr","seq":2,"streamType":4}`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_max_rate(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&maxRate=5", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	go func() {
+		var burst []byte
+		for i := 0; i < 10; i++ {
+			burst = append(burst, makeMessage(fmt.Sprintf("2020-05-13T18:55:37.772853839Z INFO burst %d\n", i), docker.STDOUT)...)
+		}
+		writer.Write(burst)
+		time.Sleep(300 * time.Millisecond)
+		writer.Write(makeMessage("2020-05-13T18:55:38.772853839Z INFO after\n", docker.STDOUT))
+		writer.Close()
+	}()
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, 6, strings.Count(rr.Body.String(), "data: {\"m\""))
+	require.Contains(t, rr.Body.String(), "INFO burst 4")
+	require.NotContains(t, rr.Body.String(), "INFO burst 5")
+	require.Contains(t, rr.Body.String(), "\"m\":\"INFO after\"")
+	require.Contains(t, rr.Body.String(), "\"sampled\":true,\"skipped\":5}")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_sanitizeMessage(t *testing.T) {
+	event := &docker.LogEvent{Message: "binary \xff\xfe output"}
+	sanitizeMessage(event)
+	require.Equal(t, "binary \uFFFD output", event.Message)
+
+	event = &docker.LogEvent{Message: map[string]string{"msg": "bad \xff", "ok": "fine"}}
+	sanitizeMessage(event)
+	require.Equal(t, map[string]string{"msg": "bad \uFFFD", "ok": "fine"}, event.Message)
+
+	event = &docker.LogEvent{Message: "héllo"}
+	sanitizeMessage(event)
+	require.Equal(t, "héllo", event.Message)
+}
+
+func Test_handler_streamLogs_sanitize_utf8(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&sanitizeUtf8=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z binary \xff\xfe output\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), "\"m\":\"binary \uFFFD output\"")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_truncateMessage(t *testing.T) {
+	tests := []struct {
+		input     string
+		max       int
+		expected  string
+		truncated bool
+	}{
+		{"hello", 10, "hello", false},
+		{"hello world", 5, "hello…", true},
+		{"héllo", 2, "h…", true},
+		{"日本語", 4, "日…", true},
+	}
+
+	for _, test := range tests {
+		event := &docker.LogEvent{Message: test.input}
+		truncateMessage(event, test.max)
+		require.Equal(t, test.expected, event.Message, test.input)
+		require.Equal(t, test.truncated, event.Truncated, test.input)
+	}
+}
+
+func Test_handler_count_logs(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/count?stdout=1&stderr=1&filter=stderr", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO Testing stderr logs...\n", docker.STDERR)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z INFO Testing stderr logs again...\n", docker.STDERR)
+	data := append(append(first, second...), third...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"count":2}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_retry_hint(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, SSERetry: 2 * time.Second})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_logs_since_start(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/since-start?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	started := time.Date(2020, 5, 13, 18, 0, 0, 0, time.UTC)
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Started: started}, nil).Once()
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, started, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_logs_since_start_never_started(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/since-start?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_dedupe(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&dedupe=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	var data []byte
+	for i, message := range []string{"INFO healthy", "INFO healthy", "INFO healthy", "WARN slow", "INFO healthy"} {
+		data = append(data, makeMessage(fmt.Sprintf("2020-05-13T18:5%d:37.772853839Z %s\n", i, message), docker.STDOUT)...)
+	}
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_dedupe_max_duration(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&dedupe=true&maxDuration=100ms&noPing=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	go func() {
+		for i := 0; i < 3; i++ {
+			writer.Write(makeMessage(fmt.Sprintf("2020-05-13T18:5%d:37.772853839Z INFO healthy\n", i), docker.STDOUT))
+		}
+		time.Sleep(300 * time.Millisecond)
+		writer.CloseWithError(context.Canceled)
+	}()
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	body := rr.Body.String()
+	require.Contains(t, body, `"repeated":2`, "Expected the held back repeats to be sent before the stream ended")
+	require.Less(t, strings.Index(body, `"repeated":2`), strings.Index(body, "event: stream-timeout"))
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_follow_name(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/old/logs/stream?stdout=1&follow=name&maxDuration=200ms", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	recreatePollInterval = time.Millisecond
+	defer func() { recreatePollInterval = time.Second }()
+
+	mockedClient := new(MockedClient)
+	before := makeMessage("2020-05-13T18:55:37.772853839Z INFO before recreate\n", docker.STDOUT)
+	after := makeMessage("2020-05-13T18:56:37.772853839Z INFO after recreate\n", docker.STDOUT)
+
+	mockedClient.On("FindContainer", "old").Return(docker.Container{ID: "old", Name: "web"}, nil)
+	mockedClient.On("FindContainer", "new").Return(docker.Container{ID: "new", Name: "web"}, nil)
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: "new", Name: "web", State: "running"}}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "old", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(before)), nil)
+	mockedClient.On("ContainerLogs", mock.Anything, "new", "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(after)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_malformed_from(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&from=2018-01-01T00:00:00", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid from")
+	mockedClient.AssertNotCalled(t, "ContainerLogsBetweenDates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_handler_between_dates_omitted(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	now := time.Now()
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, time.Time{}, mock.MatchedBy(func(to time.Time) bool {
+		return !to.Before(now) && to.Sub(now) < time.Minute
+	}), docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_no_follow(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&tail=1&follow=false", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO last line\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsTail", mock.Anything, id, 1, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+	mockedClient.AssertNotCalled(t, "ContainerLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_handler_streamLogs_invalid_follow(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs/stream?stdout=1&follow=maybe", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_handler_between_dates_empty(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_since_now(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&sinceNow=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	now := time.Now().UnixMilli()
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, mock.MatchedBy(func(since string) bool {
+		millis, err := strconv.ParseInt(since, 10, 64)
+		return err == nil && millis >= now && millis-now < 60000
+	}), docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_since_now_last_event_id(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&sinceNow=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+	req.Header.Set("Last-Event-ID", "1589396137772")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137771", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), io.EOF)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_no_ping(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&noPing=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	reader, writer := io.Pipe()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		writer.Write(makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT))
+		writer.Close()
+	}()
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, KeepAliveInterval: time.Millisecond})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.NotContains(t, rr.Body.String(), ":ping")
+	require.Contains(t, rr.Body.String(), "INFO Testing logs...")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_desc(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&order=desc&limit=2", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	var data []byte
+	for i := 1; i <= 4; i++ {
+		data = append(data, makeMessage(fmt.Sprintf("2020-05-13T18:5%d:37.772853839Z INFO line %d\n", i, i), docker.STDOUT)...)
+	}
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_desc_requires_limit(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs?stdout=1&order=desc", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_handler_between_dates_host_query(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&host=remote", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	localClient := new(MockedClient)
+	remoteClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO from remote\n", docker.STDOUT)
+	remoteClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	remoteClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createRouter(&handler{
+		clients: map[string]docker.Client{"localhost": localClient, "remote": remoteClient},
+		config:  &Config{Base: "/", Authorization: Authorization{Provider: NONE}},
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "from remote")
+	remoteClient.AssertExpectations(t)
+	localClient.AssertExpectations(t)
+}
+
+func Test_handler_unknown_host(t *testing.T) {
+	for _, url := range []string{
+		"/api/hosts/localhost/containers/123456/logs?stdout=1&host=unknown",
+		"/api/hosts/unknown/containers/123456/logs?stdout=1",
+	} {
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		handler := createDefaultHandler(new(MockedClient))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code, url)
+		require.Equal(t, "unknown host \"unknown\"\n", rr.Body.String(), url)
+	}
+}
+
+func Test_handler_between_dates_unsupported_log_driver(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, LogDriver: "awslogs"}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), errors.New("Error response from daemon: configured logging driver does not support reading"))
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.JSONEq(t, `{"error":"logging driver does not support reading logs","id":"123456","driver":"awslogs"}`, rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_tz(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&tz=Asia/Tokyo", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"ts":1589396137772`)
+	require.Contains(t, rr.Body.String(), `"time":"2020-05-14T03:55:37.772+09:00"`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_invalid_tz(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/123456/logs?stdout=1&tz=Mars/Olympus", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(new(MockedClient))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid tz")
+}
+
+func Test_parseTime(t *testing.T) {
+	now := time.Date(2020, 5, 13, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+		err      bool
+	}{
+		{"2020-05-13T17:00:00Z", time.Date(2020, 5, 13, 17, 0, 0, 0, time.UTC), false},
+		{"-1h", now.Add(-time.Hour), false},
+		{"-30m", now.Add(-30 * time.Minute), false},
+		{"-abc", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		actual, err := parseTime(test.input, now)
+		if test.err {
+			require.Error(t, err, test.input)
+			continue
+		}
+		require.NoError(t, err, test.input)
+		require.True(t, test.expected.Equal(actual), "parseTime(%s) = %s, want %s", test.input, actual, test.expected)
+	}
+}
+
+func Test_handler_streamLogs_filter(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	q := req.URL.Query()
+	q.Add("stdout", "true")
+	q.Add("stderr", "true")
+	q.Add("filter", "err(or)?")
+
+	req.URL.RawQuery = q.Encode()
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z ERROR Something went wrong with error\n", docker.STDERR)
+	data := append(first, second...)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, mock.Anything, "", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_field_filter(t *testing.T) {
+	for _, parseJson := range []string{"false", "true"} {
+		id := "123456"
+		req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1&field.service=api&field.pid=42&parseJson="+parseJson, nil)
+		require.NoError(t, err, "NewRequest should not return an error.")
+
+		mockedClient := new(MockedClient)
+		data := makeMessage("2020-05-13T18:55:37.772853839Z {\"service\":\"api\",\"pid\":42,\"msg\":\"kept\"}\n", docker.STDOUT)
+		data = append(data, makeMessage("2020-05-13T18:55:38.772853839Z {\"service\":\"api\",\"pid\":7,\"msg\":\"other pid\"}\n", docker.STDOUT)...)
+		data = append(data, makeMessage("2020-05-13T18:55:39.772853839Z {\"service\":\"web\",\"pid\":42,\"msg\":\"other service\"}\n", docker.STDOUT)...)
+		data = append(data, makeMessage("2020-05-13T18:55:40.772853839Z service=api pid=42 msg=logfmt\n", docker.STDOUT)...)
+		data = append(data, makeMessage("2020-05-13T18:55:41.772853839Z INFO plain api line from pid 42\n", docker.STDOUT)...)
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+		mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+		handler := createDefaultHandler(mockedClient)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		body := rr.Body.String()
+		require.Contains(t, body, "kept", parseJson)
+		require.Contains(t, body, "logfmt", parseJson)
+		require.NotContains(t, body, "other pid", parseJson)
+		require.NotContains(t, body, "other service", parseJson)
+		require.NotContains(t, body, "plain api line", parseJson, "Expected plain messages to never match a field")
+		mockedClient.AssertExpectations(t)
+	}
+}
+
+func Test_handler_streamLogs_client_limit(t *testing.T) {
+	id := "123456"
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	started := make(chan struct{})
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).
+		Run(func(args mock.Arguments) { close(started) }).Return(reader, nil).Once()
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("INFO Testing logs...\n")), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, MaxClientStreams: 1})
+	stream := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	done := make(chan struct{})
+	go func() {
+		stream("10.0.0.1:4000")
+		close(done)
+	}()
+	<-started
+
+	rr := stream("10.0.0.1:4001")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code, "Expected a second stream from the same address to be rejected")
+	require.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	rr = stream("10.0.0.2:4000")
+	require.Equal(t, http.StatusOK, rr.Code, "Expected other addresses to have their own limit")
+
+	writer.Close()
+	<-done
+	rr = stream("10.0.0.1:4002")
+	require.Equal(t, http.StatusOK, rr.Code, "Expected the stream to be released after it ended")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_client_limit_by_name(t *testing.T) {
+	id := "123456"
+	mockedClient := new(MockedClient)
+
+	reader, writer := io.Pipe()
+	started := make(chan struct{})
+
+	mockedClient.On("ListContainers").Return([]docker.Container{{ID: id, Name: "web"}}, nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Name: "web", Tty: true}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).
+		Run(func(args mock.Arguments) { close(started) }).Return(reader, nil).Once()
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, MaxClientStreams: 1})
+	stream := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	done := make(chan struct{})
+	go func() {
+		stream("/api/hosts/localhost/containers/name/web/logs/stream?stdout=1")
+		close(done)
+	}()
+	<-started
+
+	for _, path := range []string{
+		"/api/hosts/localhost/containers/" + id + "/logs/stream?stdout=1",
+		"/api/hosts/localhost/containers/" + id + "/logs/stream.ndjson?stdout=1",
+		"/api/hosts/localhost/containers/" + id + "/logs/poll?stdout=1",
+		"/api/hosts/localhost/logs/merged?ids=" + id + "&stdout=1",
+	} {
+		rr := stream(path)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code, "Expected %s to count the stream by name", path)
+	}
+
+	writer.Close()
+	<-done
+	mockedClient.AssertExpectations(t)
+}
+
+// slowWriter blocks the write of the first log event until release is closed, like a client that stopped
+// reading
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (s *slowWriter) Write(p []byte) (int, error) {
+	if s.blocked != nil && bytes.HasPrefix(p, []byte(`data: {"m":`)) {
+		close(s.blocked)
+		s.blocked = nil
+		<-s.release
+	}
+	return s.ResponseRecorder.Write(p)
+}
+
+func (s *slowWriter) WriteString(str string) (int, error) {
+	return s.Write([]byte(str))
+}
+
+func Test_handler_streamLogs_slow_client(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	var data strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&data, "INFO line %d\n", i)
+	}
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id, Tty: true}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader(data.String())), nil)
+
+	handler := createHandler(mockedClient, nil, Config{Base: "/", Authorization: Authorization{Provider: NONE}, StreamQueueSize: 2})
+	rr := &slowWriter{ResponseRecorder: httptest.NewRecorder(), blocked: make(chan struct{}), release: make(chan struct{})}
+	blocked := rr.blocked
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rr, req)
+		close(done)
+	}()
+
+	<-blocked
+	// the rest of the logs are read while the client is stuck
+	time.Sleep(100 * time.Millisecond)
+	close(rr.release)
+	<-done
+
+	body := rr.Body.String()
+	require.Contains(t, body, "event: dropped\n")
+	require.Contains(t, body, `"m":"INFO line 10\n"`, "Expected the newest events to be kept")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogs_invalid_filter(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream", nil)
+	q := req.URL.Query()
+	q.Add("stdout", "true")
+	q.Add("filter", "[a-z")
+
+	req.URL.RawQuery = q.Encode()
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_json(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	from, _ := time.Parse(time.RFC3339, "2018-01-01T00:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2018-01-01T010:00:00Z")
+
+	q := req.URL.Query()
+	q.Add("from", from.Format(time.RFC3339))
+	q.Add("to", to.Format(time.RFC3339))
+	q.Add("stdout", "true")
+	q.Add("stderr", "true")
+	q.Add("format", "json")
+
+	req.URL.RawQuery = q.Encode()
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO Testing stderr logs...\n", docker.STDERR)
+	data := append(first, second...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, from, to, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var events []docker.LogEvent
+	require.Equal(t, "application/json; charset=UTF-8", rr.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &events))
+	require.Len(t, events, 2)
+	require.Equal(t, "stderr", events[1].Stream)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_json_empty(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&format=json", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(strings.NewReader("")), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, "[]\n", rr.Body.String())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_levels(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&stderr=1&levels=error,warn", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z ERROR Testing stderr logs...\n", docker.STDERR)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z no level here\n", docker.STDOUT)
+	data := append(append(first, second...), third...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_levels_include_unknown(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&stderr=1&levels=error&includeUnknownLevel=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing stdout logs...\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z ERROR Testing stderr logs...\n", docker.STDERR)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z no level here\n", docker.STDOUT)
+	data := append(append(first, second...), third...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_pagination(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&offset=1&limit=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO first\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z INFO second\n", docker.STDOUT)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z INFO third\n", docker.STDOUT)
+	data := append(append(first, second...), third...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_contains(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&contains=DATABASE&ci=true&filter=timeout", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	first := makeMessage("2020-05-13T18:55:37.772853839Z INFO connected to database\n", docker.STDOUT)
+	second := makeMessage("2020-05-13T18:56:37.772853839Z ERROR database timeout\n", docker.STDOUT)
+	third := makeMessage("2020-05-13T18:57:37.772853839Z ERROR cache timeout\n", docker.STDOUT)
+	data := append(append(first, second...), third...)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_details(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&details=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("2020-05-13T18:55:37.772853839Z env=prod INFO connected to database\n", docker.STDOUT)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, true).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Contains(t, rr.Body.String(), `"m":"INFO connected to database"`)
+	require.Contains(t, rr.Body.String(), `"details":{"env":"prod"}`)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_between_dates_timing(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs?stdout=1&timing=true", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO connected to database\n", docker.STDOUT)
+
+	mockedClient.On("ContainerLogsBetweenDates", mock.Anything, id, mock.Anything, mock.Anything, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	result := rr.Result()
+	require.Equal(t, "Server-Timing", result.Header.Get("Trailer"))
+	require.Regexp(t, `^docker;dur=[0-9.]+, encode;dur=[0-9.]+, total;dur=[0-9.]+$`, result.Trailer.Get("Server-Timing"))
+	mockedClient.AssertExpectations(t)
+}
+
+func makeMessage(message string, stream docker.StdType) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[4:], uint32(len(message)))
+	data[0] = byte(stream / 2)
+	data = append(data, []byte(message)...)
+
+	return data
+}
+
+// busyReader sends a log line every millisecond until ctx is done, like the logs of a busy container that Docker
+// closes once the request is cancelled
+type busyReader struct {
+	ctx context.Context
+}
+
+func (b busyReader) Read(p []byte) (int, error) {
+	select {
+	case <-b.ctx.Done():
+		return 0, io.EOF
+	case <-time.After(time.Millisecond):
+	}
+	return copy(p, makeMessage("2020-05-13T18:55:37.772853839Z INFO busy\n", docker.STDOUT)), nil
+}
+
+func (b busyReader) Close() error {
+	return nil
+}
+
+// requireGoroutinesDone waits for every goroutine started since before was counted to finish
+func requireGoroutinesDone(t *testing.T, before int) {
+	// not require.Eventually, which checks its condition in a goroutine of its own
+	for deadline := time.Now().Add(2 * time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), before, "Expected the goroutines of the closed streams to finish")
 }
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+	"golang.org/x/net/websocket"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// websocketHello is the first message a client sends after the connection is upgraded
+type websocketHello struct {
+	LastEventId string `json:"lastEventId"`
+}
+
+func (h *handler) streamLogsWebSocket(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	stdTypes := h.stdTypesFromRequest(r)
+	if stdTypes == 0 {
+		http.Error(w, "stdout or stderr is required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tail := docker.DefaultTail
+	if r.URL.Query().Has("tail") {
+		tail, err = strconv.Atoi(r.URL.Query().Get("tail"))
+		if err != nil || tail < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	client := h.clientFromRequest(r)
+	container, err := client.FindContainer(id)
+	if err != nil {
+		writeContainerNotFound(w, http.StatusNotFound, id, err)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: checkSameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			var hello websocketHello
+			ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+			if err := websocket.JSON.Receive(ws, &hello); err != nil {
+				log.Debugf("unable to read first websocket message: %v", err)
+				return
+			}
+			ws.SetReadDeadline(time.Time{})
+
+			// hijacked connections don't cancel the request context so watch for the client going away
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			go func() {
+				io.Copy(io.Discard, ws)
+				cancel()
+			}()
+
+			reader, err := client.ContainerLogs(ctx, container.ID, sinceFromEventId(hello.LastEventId), tail, stdTypes, false)
+			if err != nil {
+				if err != io.EOF {
+					log.Errorf("error while opening logs for websocket %v", err)
+				}
+				return
+			}
+
+			g := h.newEventGenerator(reader, container.Tty)
+			// cancel stops docker once the socket is done, the generator is drained so it can finish
+			defer drainEvents(g)
+
+			for {
+				select {
+				case event, ok := <-g.Events:
+					if !ok {
+						log.WithFields(log.Fields{"id": id}).Debug("websocket stream closed")
+						return
+					}
+					if !filter.matches(event) {
+						continue
+					}
+					buf, err := json.Marshal(event)
+					if err != nil {
+						log.Errorf("json encoding error while streaming %v", err.Error())
+						continue
+					}
+					if err := websocket.Message.Send(ws, string(buf)); err != nil {
+						log.Debugf("error writing to websocket: %v", err)
+						return
+					}
+				case <-h.shutdown:
+					if err := websocket.JSON.Send(ws, serverShutdown); err != nil {
+						log.Debugf("error writing to websocket: %v", err)
+					}
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// checkSameOrigin rejects browser connections from other sites. Clients without an Origin header are allowed.
+func checkSameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != r.Host {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	config.Origin = origin
+	return nil
+}
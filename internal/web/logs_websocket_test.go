@@ -0,0 +1,117 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func Test_handler_streamLogsWebSocket(t *testing.T) {
+	id := "123456"
+
+	mockedClient := new(MockedClient)
+
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...", docker.STDOUT)
+
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "1589396137772", docker.DefaultTail, docker.STDALL, false).Return(io.NopCloser(bytes.NewReader(data)), nil)
+
+	server := httptest.NewServer(createDefaultHandler(mockedClient))
+	defer server.Close()
+
+	url := strings.Replace(server.URL, "http", "ws", 1) + "/api/hosts/localhost/containers/" + id + "/logs/ws?stdout=1&stderr=1"
+	ws, err := websocket.Dial(url, "", server.URL)
+	require.NoError(t, err, "Dial should not return an error.")
+	defer ws.Close()
+
+	require.NoError(t, websocket.JSON.Send(ws, websocketHello{LastEventId: "1589396137772"}))
+
+	var message string
+	require.NoError(t, websocket.Message.Receive(ws, &message))
+	require.Equal(t, `{"m":"INFO Testing logs...","ts":1589396137772,"id":1469707724,"l":"info","s":"stdout"}`, message)
+
+	require.Error(t, websocket.Message.Receive(ws, &message), "connection should be closed when the container stops")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogsWebSocket_cross_origin(t *testing.T) {
+	id := "123456"
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+
+	server := httptest.NewServer(createDefaultHandler(mockedClient))
+	defer server.Close()
+
+	url := strings.Replace(server.URL, "http", "ws", 1) + "/api/hosts/localhost/containers/" + id + "/logs/ws?stdout=1"
+	_, err := websocket.Dial(url, "", "http://evil.example.com")
+	require.Error(t, err, "Dial should fail for a different origin.")
+}
+
+func Test_handler_streamLogsWebSocket_server_shutdown(t *testing.T) {
+	id := "123456"
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	mockedClient := new(MockedClient)
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(reader, nil)
+
+	shutdown := make(chan struct{})
+	close(shutdown)
+	server := httptest.NewServer(createRouter(&handler{
+		clients:  map[string]docker.Client{"localhost": mockedClient},
+		config:   &Config{Base: "/", KeepAliveInterval: time.Minute, Authorization: Authorization{Provider: NONE}},
+		shutdown: shutdown,
+	}))
+	defer server.Close()
+
+	url := strings.Replace(server.URL, "http", "ws", 1) + "/api/hosts/localhost/containers/" + id + "/logs/ws?stdout=1"
+	ws, err := websocket.Dial(url, "", server.URL)
+	require.NoError(t, err, "Dial should not return an error.")
+	defer ws.Close()
+
+	require.NoError(t, websocket.JSON.Send(ws, websocketHello{}))
+
+	var message string
+	require.NoError(t, websocket.Message.Receive(ws, &message))
+	require.Equal(t, `{"event":"server-shutdown","data":"server is shutting down"}`, message)
+
+	require.Error(t, websocket.Message.Receive(ws, &message), "connection should be closed after the shutdown message")
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_streamLogsWebSocket_disconnect(t *testing.T) {
+	id := "123456"
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		mockedClient := new(MockedClient)
+		reader := &busyReader{}
+		mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+		mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Run(func(args mock.Arguments) {
+			reader.ctx = args.Get(0).(context.Context)
+		}).Return(reader, nil)
+
+		server := httptest.NewServer(createDefaultHandler(mockedClient))
+		url := strings.Replace(server.URL, "http", "ws", 1) + "/api/hosts/localhost/containers/" + id + "/logs/ws?stdout=1"
+		ws, err := websocket.Dial(url, "", server.URL)
+		require.NoError(t, err, "Dial should not return an error.")
+		require.NoError(t, websocket.JSON.Send(ws, websocketHello{}))
+		var message string
+		require.NoError(t, websocket.Message.Receive(ws, &message))
+		ws.Close()
+		server.Close()
+	}
+	requireGoroutinesDone(t, before)
+}
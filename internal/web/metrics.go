@@ -0,0 +1,59 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	activeStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dozzle_active_streams",
+		Help: "Number of log requests currently being served.",
+	}, []string{"type"})
+
+	streamBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dozzle_stream_bytes_total",
+		Help: "Total bytes of logs written to clients.",
+	}, []string{"type"})
+)
+
+const (
+	liveStream       = "live"
+	historicalStream = "historical"
+)
+
+// instrumentStream tracks the handler in dozzle_active_streams and counts what it writes in dozzle_stream_bytes_total
+func instrumentStream(kind string) func(http.Handler) http.Handler {
+	active := activeStreams.WithLabelValues(kind)
+	bytes := streamBytes.WithLabelValues(kind)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			active.Inc()
+			defer active.Dec()
+			next.ServeHTTP(&countingResponseWriter{ResponseWriter: w, counter: bytes}, r)
+		})
+	}
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	counter prometheus.Counter
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.counter.Add(float64(n))
+	return n, err
+}
+
+func (c *countingResponseWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/auth"
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_instrumentStream(t *testing.T) {
+	id := "123456"
+	req, err := http.NewRequest("GET", "/api/hosts/localhost/containers/"+id+"/logs/stream?stdout=1", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	mockedClient := new(MockedClient)
+	data := makeMessage("2020-05-13T18:55:37.772853839Z INFO Testing logs...\n", docker.STDOUT)
+
+	before := testutil.ToFloat64(streamBytes.WithLabelValues(liveStream))
+	mockedClient.On("FindContainer", id).Return(docker.Container{ID: id}, nil)
+	mockedClient.On("ContainerLogs", mock.Anything, id, "", docker.DefaultTail, docker.STDOUT, false).Return(io.NopCloser(bytes.NewReader(data)), nil).Run(func(args mock.Arguments) {
+		require.Equal(t, 1.0, testutil.ToFloat64(activeStreams.WithLabelValues(liveStream)))
+	})
+
+	handler := createDefaultHandler(mockedClient)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, 0.0, testutil.ToFloat64(activeStreams.WithLabelValues(liveStream)))
+	require.Equal(t, float64(rr.Body.Len()), testutil.ToFloat64(streamBytes.WithLabelValues(liveStream))-before)
+	mockedClient.AssertExpectations(t)
+}
+
+func Test_handler_metrics(t *testing.T) {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `dozzle_active_streams{type="live"}`)
+	require.Contains(t, rr.Body.String(), `dozzle_stream_bytes_total{type="historical"}`)
+}
+
+func Test_handler_metrics_requires_authentication(t *testing.T) {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createHandler(nil, nil, Config{Base: "/",
+		Authorization: Authorization{
+			Provider:   SIMPLE,
+			Authorizer: auth.NewSimpleAuth(auth.UserDatabase{Users: map[string]*auth.User{}}),
+		},
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.NotContains(t, rr.Body.String(), "dozzle_active_streams")
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isPrecompressed peeks at the first line of the logs and reports whether it is gzip once the timestamp Docker
+// prefixes it with is skipped. Nothing is consumed from reader.
+func isPrecompressed(reader *bufio.Reader, tty bool) bool {
+	// a timestamp with nanoseconds and the space after it, plus the magic bytes
+	size := len(time.RFC3339Nano) + 1 + len(gzipMagic)
+	if !tty {
+		size += 8
+	}
+	peeked, _ := reader.Peek(size)
+	if !tty {
+		if len(peeked) < 8 {
+			return false
+		}
+		peeked = peeked[8:]
+	}
+	return bytes.HasPrefix(stripTimestamp(peeked), gzipMagic)
+}
+
+// stripTimestamp removes the timestamp and the space after it from the start of a line
+func stripTimestamp(line []byte) []byte {
+	if index := bytes.IndexByte(line, ' '); index != -1 {
+		if _, err := time.Parse(time.RFC3339Nano, string(line[:index])); err == nil {
+			return line[index+1:]
+		}
+	}
+	return line
+}
+
+// copyWithoutTimestamps copies the bytes of the logs as the container wrote them, with the frame headers and the
+// timestamps on every line removed so a stream that is already gzip comes out unchanged
+func copyWithoutTimestamps(dst io.Writer, src *bufio.Reader, tty bool) error {
+	header := make([]byte, 8)
+	for {
+		var line []byte
+		var err error
+		if tty {
+			line, err = src.ReadBytes('\n')
+		} else {
+			if _, err = io.ReadFull(src, header); err == nil {
+				line = make([]byte, binary.BigEndian.Uint32(header[4:]))
+				_, err = io.ReadFull(src, line)
+			}
+		}
+		if len(line) > 0 {
+			if _, err := dst.Write(stripTimestamp(line)); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -2,15 +2,20 @@ package web
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"io/fs"
 
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/amir20/dozzle/internal/auth"
 	"github.com/amir20/dozzle/internal/docker"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,6 +37,49 @@ type Config struct {
 	Dev           bool
 	Authorization Authorization
 	EnableActions bool
+	// EnableExport allows uploading logs to a pre-signed url, which makes Dozzle send requests to other servers
+	EnableExport bool
+	// ExportAllowedHosts are the only hosts exports can be uploaded to, which may then be private. Without them any
+	// public address is allowed.
+	ExportAllowedHosts []string
+	// KeepAliveInterval is the time between pings sent on idle log streams
+	KeepAliveInterval time.Duration
+	// DownloadBufferLimit is the largest download in bytes that is buffered to send a Content-Length
+	DownloadBufferLimit int
+	// DownloadMaxBytes truncates the logs of downloads after this many bytes, zero means no limit
+	DownloadMaxBytes int64
+	// StreamRetries is how many times a log stream reconnects to Docker after a transient error
+	StreamRetries int
+	// DownloadRetries is how many times opening the logs of a download is retried after a transient error
+	DownloadRetries int
+	// MaxConcurrentDownloads is how many log downloads can run at once, zero means no limit
+	MaxConcurrentDownloads int
+	// MaxClientStreams is how many log streams one user or address can have open at once, zero means no limit
+	MaxClientStreams int
+	// SSERetry is the reconnection delay sent to EventSource clients, zero leaves the browser default
+	SSERetry time.Duration
+	// DownloadFilename is the template for download filenames, see DefaultFilenameTemplate
+	DownloadFilename string
+	// StreamQueueSize is how many events a log stream holds for a slow client before dropping the oldest
+	StreamQueueSize int
+	// StreamLabels are the container label keys included in the container-info event of log streams
+	StreamLabels []string
+	// SnapshotMaxContainers is the most running containers a snapshot download includes, zero means no limit
+	SnapshotMaxContainers int
+	// SnapshotMaxBytes is the largest snapshot archive in bytes, zero means no limit
+	SnapshotMaxBytes int64
+	// EventBufferSize is how many parsed events each log reader buffers ahead of the handler, zero is unbuffered
+	EventBufferSize int
+	// LevelPattern replaces the built-in level guessing for lines it matches, nil keeps the guessing
+	LevelPattern *regexp.Regexp
+	// StreamReadTimeout ends log streams when Docker sends nothing for this long, zero means no timeout
+	StreamReadTimeout time.Duration
+	// DefaultStdTypes are the streams of requests that ask for neither stdout nor stderr, zero means both
+	DefaultStdTypes docker.StdType
+	// StrictStdTypes rejects requests that ask for neither stdout nor stderr instead of using DefaultStdTypes
+	StrictStdTypes bool
+	// CorsOrigins are the origins allowed to read log streams, history and downloads from another site
+	CorsOrigins []string
 }
 
 type Authorization struct {
@@ -49,6 +97,9 @@ type handler struct {
 	stores  map[string]*docker.ContainerStore
 	content fs.FS
 	config  *Config
+
+	// shutdown is closed when the server starts shutting down so that open streams can end cleanly
+	shutdown chan struct{}
 }
 
 func CreateServer(clients map[string]docker.Client, content fs.FS, config Config) *http.Server {
@@ -58,13 +109,18 @@ func CreateServer(clients map[string]docker.Client, content fs.FS, config Config
 	}
 
 	handler := &handler{
-		clients: clients,
-		content: content,
-		config:  &config,
-		stores:  stores,
+		clients:  clients,
+		content:  content,
+		config:   &config,
+		stores:   stores,
+		shutdown: make(chan struct{}),
 	}
 
-	return &http.Server{Addr: config.Addr, Handler: createRouter(handler)}
+	srv := &http.Server{Addr: config.Addr, Handler: createRouter(handler)}
+	srv.RegisterOnShutdown(func() {
+		close(handler.shutdown)
+	})
+	return srv
 }
 
 var fileServer http.Handler
@@ -81,6 +137,10 @@ func createRouter(h *handler) *chi.Mux {
 		log.Panic("Authorization provider is set but no authorizer is provided")
 	}
 
+	// all downloads share the same slots
+	limitDownloads := limitConcurrency(h.config.MaxConcurrentDownloads)
+	limitStreams := limitPerClient(h.config.MaxClientStreams)
+
 	r.Route(base, func(r chi.Router) {
 		if h.config.Authorization.Provider != NONE {
 			r.Use(h.config.Authorization.Authorizer.AuthMiddleware)
@@ -90,10 +150,36 @@ func createRouter(h *handler) *chi.Mux {
 				if h.config.Authorization.Provider != NONE {
 					r.Use(auth.RequireAuthentication)
 				}
-				r.Get("/api/hosts/{host}/containers/{id}/logs/stream", h.streamLogs)
-				r.Get("/api/hosts/{host}/containers/{id}/logs/download", h.downloadLogs)
-				r.Get("/api/hosts/{host}/containers/{id}/logs", h.fetchLogsBetweenDates)
+				r.Use(h.requireKnownHost)
+				// every kind of log stream counts against the same per client limit
+				r.Group(func(r chi.Router) {
+					r.Use(limitStreams)
+					r.With(h.cors, instrumentStream(liveStream)).Get("/api/hosts/{host}/containers/{id}/logs/stream", h.streamLogs)
+					r.With(instrumentStream(liveStream)).Get("/api/hosts/{host}/containers/{id}/logs/stream.ndjson", h.streamLogsNDJSON)
+					r.With(instrumentStream(liveStream)).Get("/api/hosts/{host}/containers/name/{name}/logs/stream", h.streamLogsByName)
+					r.With(instrumentStream(liveStream)).Get("/api/hosts/{host}/containers/{id}/logs/counts/stream", h.streamLogCounts)
+					r.Get("/api/hosts/{host}/containers/{id}/logs/ws", h.streamLogsWebSocket)
+					r.With(instrumentStream(liveStream)).Get("/api/hosts/{host}/containers/{id}/logs/poll", h.pollLogs)
+					r.With(instrumentStream(liveStream)).Get("/api/hosts/{host}/logs/merged", h.streamMergedLogs)
+				})
+				r.With(h.cors, limitDownloads, instrumentStream(historicalStream)).Get("/api/hosts/{host}/containers/{id}/logs/download", h.downloadLogs)
+				r.Head("/api/hosts/{host}/containers/{id}/logs/download", h.headDownloadLogs)
+				r.With(h.cors, instrumentStream(historicalStream)).Get("/api/hosts/{host}/containers/{id}/logs", h.fetchLogsBetweenDates)
+				r.Get("/api/hosts/{host}/containers/{id}/logs/count", h.countLogs)
+				r.Get("/api/hosts/{host}/containers/{id}/logs/bounds", h.fetchLogBounds)
+				r.With(instrumentStream(historicalStream)).Get("/api/hosts/{host}/containers/{id}/logs/events/{eventId}", h.fetchLogEvent)
+				r.With(instrumentStream(historicalStream)).Get("/api/hosts/{host}/containers/{id}/logs/diff", h.diffLogs)
+				r.Get("/api/hosts/{host}/containers/{id}/logs/filter-preview", h.previewFilter)
+				r.With(instrumentStream(historicalStream)).Get("/api/hosts/{host}/containers/{id}/logs/since-start", h.fetchLogsSinceStart)
+				r.With(limitDownloads, instrumentStream(historicalStream)).Get("/api/hosts/{host}/logs/download", h.downloadArchive)
+				r.With(limitDownloads, instrumentStream(historicalStream)).Get("/api/hosts/{host}/logs/snapshot", h.downloadSnapshot)
+				r.Get("/api/hosts/{host}/containers/resolve", h.resolveContainers)
+				r.Get("/api/hosts/{host}/containers/{id}", h.containerMetadata)
 				r.Get("/api/events/stream", h.streamEvents)
+				r.Get("/api/schema/log-event", h.logEventSchema)
+				if h.config.EnableExport {
+					r.With(limitDownloads, instrumentStream(historicalStream)).Post("/api/hosts/{host}/containers/{id}/logs/export", h.exportLogs)
+				}
 				if h.config.EnableActions {
 					r.Post("/api/hosts/{host}/containers/{id}/actions/{action}", h.containerActions)
 				}
@@ -101,6 +187,7 @@ func createRouter(h *handler) *chi.Mux {
 				r.Get("/api/profile/avatar", h.avatar)
 				r.Patch("/api/profile", h.updateProfile)
 				r.Get("/version", h.version)
+				r.Handle("/metrics", promhttp.Handler())
 			})
 
 			defaultHandler := http.StripPrefix(strings.Replace(base+"/", "//", "/", 1), http.HandlerFunc(h.index))
@@ -114,7 +201,14 @@ func createRouter(h *handler) *chi.Mux {
 			r.Delete("/api/token", h.deleteToken)
 		}
 
+		if len(h.config.CorsOrigins) > 0 {
+			r.Options("/api/hosts/{host}/containers/{id}/logs/stream", h.corsPreflight)
+			r.Options("/api/hosts/{host}/containers/{id}/logs/download", h.corsPreflight)
+			r.Options("/api/hosts/{host}/containers/{id}/logs", h.corsPreflight)
+		}
+
 		r.Get("/healthcheck", h.healthcheck)
+		r.Get("/healthz", h.healthz)
 
 		// r.Mount("/debug", middleware.Profiler())
 	})
@@ -130,8 +224,43 @@ func createRouter(h *handler) *chi.Mux {
 	return r
 }
 
+// hostFromRequest returns the host from the ?host= query parameter, falling back to the host in the path
+func hostFromRequest(r *http.Request) string {
+	if host := r.URL.Query().Get("host"); host != "" {
+		return host
+	}
+	return chi.URLParam(r, "host")
+}
+
+// requireKnownHost rejects requests for hosts Dozzle is not connected to before a handler looks up their client
+func (h *handler) requireKnownHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if host := hostFromRequest(r); host != "" {
+			if _, ok := h.clients[host]; !ok {
+				http.Error(w, fmt.Sprintf("unknown host %q", host), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newEventGenerator creates the event generator for a log reader with the configured buffer and level pattern
+func (h *handler) newEventGenerator(reader io.Reader, tty bool) *docker.EventGenerator {
+	return h.newEventGeneratorWithDetails(reader, tty, false)
+}
+
+// newEventGeneratorWithDetails is newEventGenerator that also parses details for readers opened with details
+func (h *handler) newEventGeneratorWithDetails(reader io.Reader, tty bool, details bool) *docker.EventGenerator {
+	return docker.NewEventGeneratorWithOptions(reader, tty, docker.EventGeneratorOptions{
+		BufferSize:   h.config.EventBufferSize,
+		LevelPattern: h.config.LevelPattern,
+		Details:      details,
+	})
+}
+
 func (h *handler) clientFromRequest(r *http.Request) docker.Client {
-	host := chi.URLParam(r, "host")
+	host := hostFromRequest(r)
 
 	if host == "" {
 		log.Fatalf("No host found for url %v", r.URL)
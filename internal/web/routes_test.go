@@ -8,6 +8,7 @@ import (
 	"io/fs"
 
 	"github.com/amir20/dozzle/internal/docker"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/system"
 	"github.com/go-chi/chi/v5"
 
@@ -36,8 +37,13 @@ func (m *MockedClient) ListContainers() ([]docker.Container, error) {
 	return args.Get(0).([]docker.Container), args.Error(1)
 }
 
-func (m *MockedClient) ContainerLogs(ctx context.Context, id string, since string, stdType docker.StdType) (io.ReadCloser, error) {
-	args := m.Called(ctx, id, since, stdType)
+func (m *MockedClient) ContainerLogs(ctx context.Context, id string, since string, tail int, stdType docker.StdType, details bool) (io.ReadCloser, error) {
+	args := m.Called(ctx, id, since, tail, stdType, details)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockedClient) ContainerLogsTail(ctx context.Context, id string, tail int, stdType docker.StdType, details bool) (io.ReadCloser, error) {
+	args := m.Called(ctx, id, tail, stdType, details)
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
@@ -50,11 +56,16 @@ func (m *MockedClient) ContainerStats(context.Context, string, chan<- docker.Con
 	return nil
 }
 
-func (m *MockedClient) ContainerLogsBetweenDates(ctx context.Context, id string, from time.Time, to time.Time, stdType docker.StdType) (io.ReadCloser, error) {
-	args := m.Called(ctx, id, from, to, stdType)
+func (m *MockedClient) ContainerLogsBetweenDates(ctx context.Context, id string, from time.Time, to time.Time, stdType docker.StdType, details bool) (io.ReadCloser, error) {
+	args := m.Called(ctx, id, from, to, stdType, details)
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+func (m *MockedClient) Ping(ctx context.Context) (types.Ping, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(types.Ping), args.Error(1)
+}
+
 func (m *MockedClient) Host() *docker.Host {
 	args := m.Called()
 	return args.Get(0).(*docker.Host)
@@ -83,6 +94,10 @@ func createHandler(client docker.Client, content fs.FS, config Config) *chi.Mux
 		content = afero.NewIOFS(fs)
 	}
 
+	if config.KeepAliveInterval == 0 {
+		config.KeepAliveInterval = 5 * time.Second
+	}
+
 	clients := map[string]docker.Client{
 		"localhost": client,
 	}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+// logEventSchema is generated from docker.LogEvent so it can't drift from what the streams send
+var logEventSchema = func() map[string]any {
+	schema := schemaFor(reflect.TypeOf(docker.LogEvent{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "LogEvent"
+	return schema
+}()
+
+func (h *handler) logEventSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(logEventSchema); err != nil {
+		log.Errorf("json encoding error while writing schema %v", err)
+	}
+}
+
+// schemaFor describes a type with the subset of JSON Schema needed for log events. Interfaces have no
+// type since they can hold anything, like messages that are either text or parsed JSON objects.
+func schemaFor(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaFor(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beme/abide"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handler_logEventSchema(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/schema/log-event", nil)
+	require.NoError(t, err, "NewRequest should not return an error.")
+
+	handler := createDefaultHandler(nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	abide.AssertHTTPResponse(t, t.Name(), rr.Result())
+}
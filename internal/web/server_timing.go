@@ -0,0 +1,40 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// timedReader adds up the time spent waiting on reads, which happen on the event generator's goroutine
+type timedReader struct {
+	reader  io.Reader
+	elapsed atomic.Int64
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.reader.Read(p)
+	t.elapsed.Add(int64(time.Since(start)))
+	return n, err
+}
+
+func (t *timedReader) Duration() time.Duration {
+	return time.Duration(t.elapsed.Load())
+}
+
+type serverTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// formatServerTiming formats metrics as a Server-Timing value with durations in milliseconds
+func formatServerTiming(metrics ...serverTiming) string {
+	values := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		values = append(values, fmt.Sprintf("%s;dur=%.3f", metric.name, float64(metric.duration)/float64(time.Millisecond)))
+	}
+	return strings.Join(values, ", ")
+}
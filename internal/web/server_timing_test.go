@@ -0,0 +1,13 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_formatServerTiming(t *testing.T) {
+	value := formatServerTiming(serverTiming{"docker", 1500 * time.Microsecond}, serverTiming{"encode", 2 * time.Millisecond})
+	require.Equal(t, "docker;dur=1.500, encode;dur=2.000", value)
+}
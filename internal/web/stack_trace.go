@@ -0,0 +1,64 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+// defaultContinuationPattern matches indented lines, like Java's "\tat " frames and Python's "  File" lines
+var defaultContinuationPattern = regexp.MustCompile(`^\s+\S`)
+
+// stackTraceJoiner merges continuation lines into the message of the line before them
+type stackTraceJoiner struct {
+	continuation *regexp.Regexp
+	pending      *docker.LogEvent
+}
+
+// stackTraceJoinerFromRequest returns a joiner for ?joinStackTraces=true using the ?continuation= regex, or nil
+func stackTraceJoinerFromRequest(r *http.Request) (*stackTraceJoiner, error) {
+	if r.URL.Query().Get("joinStackTraces") != "true" {
+		return nil, nil
+	}
+	continuation := defaultContinuationPattern
+	if pattern := r.URL.Query().Get("continuation"); pattern != "" {
+		var err error
+		if continuation, err = regexp.Compile(pattern); err != nil {
+			return nil, errors.New("continuation must be a valid regex")
+		}
+	}
+	return &stackTraceJoiner{continuation: continuation}, nil
+}
+
+// add holds back event until its continuation lines have arrived. It returns the previous event when event
+// does not continue it, or nil.
+func (j *stackTraceJoiner) add(event *docker.LogEvent) *docker.LogEvent {
+	if j.pending != nil && j.continues(event) {
+		j.pending.Message = strings.TrimSuffix(j.pending.Message.(string), "\n") + "\n" + event.Message.(string)
+		return nil
+	}
+	complete := j.pending
+	j.pending = event
+	return complete
+}
+
+// flush returns the held back event, or nil
+func (j *stackTraceJoiner) flush() *docker.LogEvent {
+	complete := j.pending
+	j.pending = nil
+	return complete
+}
+
+func (j *stackTraceJoiner) continues(event *docker.LogEvent) bool {
+	if event.Stream != j.pending.Stream {
+		return false
+	}
+	if _, ok := j.pending.Message.(string); !ok {
+		return false
+	}
+	message, ok := event.Message.(string)
+	return ok && j.continuation.MatchString(message)
+}
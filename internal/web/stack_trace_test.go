@@ -0,0 +1,26 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_stackTraceJoiner(t *testing.T) {
+	joiner := &stackTraceJoiner{continuation: defaultContinuationPattern}
+
+	require.Nil(t, joiner.add(&docker.LogEvent{Message: "Exception in thread \"main\" java.lang.NullPointerException", Stream: "stderr"}))
+	require.Nil(t, joiner.add(&docker.LogEvent{Message: "\tat com.example.Main.run(Main.java:10)", Stream: "stderr"}))
+	require.Nil(t, joiner.add(&docker.LogEvent{Message: "\tat com.example.Main.main(Main.java:4)", Stream: "stderr"}))
+
+	trace := joiner.add(&docker.LogEvent{Message: "INFO next line", Stream: "stderr"})
+	require.Equal(t, "Exception in thread \"main\" java.lang.NullPointerException\n\tat com.example.Main.run(Main.java:10)\n\tat com.example.Main.main(Main.java:4)", trace.Message)
+
+	// lines on another stream or with parsed messages are never merged
+	require.NotNil(t, joiner.add(&docker.LogEvent{Message: "  indented", Stream: "stdout"}))
+	require.NotNil(t, joiner.add(&docker.LogEvent{Message: map[string]any{"msg": "  indented"}, Stream: "stdout"}))
+
+	require.Equal(t, map[string]any{"msg": "  indented"}, joiner.flush().Message)
+	require.Nil(t, joiner.flush())
+}
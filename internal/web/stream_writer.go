@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/goccy/go-json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// streamWriter writes what streamLogs sends in the format the client asked for. The first failed write is kept and
+// every write after it is skipped, streamLogs ends the stream once err returns it.
+type streamWriter interface {
+	setHeaders(header http.Header)
+	// retry tells the client how long to wait before reconnecting, it only means something to SSE clients
+	retry(d time.Duration)
+	// event writes a log event with the id the client resumes from and returns the size of the encoded event
+	event(event *docker.LogEvent, matches [][]int, id string) int
+	// control writes a named event such as container-info or server-shutdown
+	control(name string, data string)
+	// stopped writes container-stopped, retry stretches the wait before reconnecting when it is set
+	stopped(retry time.Duration)
+	ping()
+	err() error
+}
+
+// sseWriter is the default streamWriter, writing server-sent events with every log event as JSON
+type sseWriter struct {
+	w          io.Writer
+	keepFields map[string]bool
+	failed     error
+}
+
+func (s *sseWriter) write(format string, a ...any) {
+	if s.failed == nil {
+		_, s.failed = fmt.Fprintf(s.w, format, a...)
+	}
+}
+
+func (s *sseWriter) setHeaders(header http.Header) {
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-transform")
+	header.Add("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+}
+
+func (s *sseWriter) retry(d time.Duration) {
+	s.write("retry: %d\n\n", d.Milliseconds())
+}
+
+func (s *sseWriter) event(event *docker.LogEvent, matches [][]int, id string) int {
+	var payload any = event
+	if s.keepFields != nil {
+		payload = filterEventFields(highlightedLogEvent{LogEvent: event, Matches: matches}, s.keepFields)
+	} else if matches != nil {
+		payload = highlightedLogEvent{LogEvent: event, Matches: matches}
+	}
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("json encoding error while streaming %v", err.Error())
+		return 0
+	}
+	s.write("data: %s\nid: %s\n\n", buf, id)
+	return len(buf)
+}
+
+func (s *sseWriter) control(name string, data string) {
+	s.write("event: %s\ndata: %s\n\n", name, data)
+}
+
+func (s *sseWriter) stopped(retry time.Duration) {
+	if retry > 0 {
+		s.write("retry: %d\n", retry.Milliseconds())
+	}
+	s.control("container-stopped", "end of stream")
+}
+
+func (s *sseWriter) ping() {
+	s.write(":ping \n\n")
+}
+
+func (s *sseWriter) err() error {
+	return s.failed
+}
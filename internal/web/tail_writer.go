@@ -0,0 +1,52 @@
+package web
+
+import (
+	"bytes"
+	"io"
+)
+
+// tailWriter keeps the last size bytes written to it in a ring buffer
+type tailWriter struct {
+	buf  []byte
+	next int
+	full bool
+}
+
+func newTailWriter(size int) *tailWriter {
+	return &tailWriter{buf: make([]byte, size)}
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= len(t.buf) {
+		copy(t.buf, p[len(p)-len(t.buf):])
+		t.next, t.full = 0, true
+		return n, nil
+	}
+	copied := copy(t.buf[t.next:], p)
+	if copied < len(p) {
+		copy(t.buf, p[copied:])
+		t.full = true
+	}
+	t.next = (t.next + len(p)) % len(t.buf)
+	if t.next == 0 && len(p) > 0 {
+		t.full = true
+	}
+	return n, nil
+}
+
+// WriteTo writes the kept bytes in order. When older bytes were dropped the partial first line is skipped too,
+// so the output starts on a line, which makes it a bit shorter than size.
+func (t *tailWriter) WriteTo(w io.Writer) (int64, error) {
+	var kept []byte
+	if t.full {
+		kept = append(append(kept, t.buf[t.next:]...), t.buf[:t.next]...)
+		if i := bytes.IndexByte(kept, '\n'); i >= 0 && i < len(kept)-1 {
+			kept = kept[i+1:]
+		}
+	} else {
+		kept = t.buf[:t.next]
+	}
+	n, err := w.Write(kept)
+	return int64(n), err
+}
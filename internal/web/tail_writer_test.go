@@ -0,0 +1,34 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_tailWriter(t *testing.T) {
+	tests := []struct {
+		size     int
+		writes   []string
+		expected string
+	}{
+		{16, []string{"one\n", "two\n"}, "one\ntwo\n"},
+		{10, []string{"one\n", "two\n", "three\n"}, "three\n"},
+		{8, []string{"first line\n", "second\n"}, "second\n"},
+		{12, []string{"aaaa\nbbbb\n", "cccc\n"}, "bbbb\ncccc\n"},
+		{4, []string{"no newline at all"}, " all"},
+	}
+
+	for _, test := range tests {
+		tail := newTailWriter(test.size)
+		for _, write := range test.writes {
+			_, err := tail.Write([]byte(write))
+			require.NoError(t, err)
+		}
+		var out bytes.Buffer
+		_, err := tail.WriteTo(&out)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, out.String(), test.writes)
+	}
+}
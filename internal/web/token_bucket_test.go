@@ -0,0 +1,22 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_tokenBucket(t *testing.T) {
+	bucket := newTokenBucket(2)
+	now := time.Now()
+
+	require.True(t, bucket.allow(now))
+	require.True(t, bucket.allow(now))
+	require.False(t, bucket.allow(now), "Expected the burst to be used up")
+	require.False(t, bucket.allow(now.Add(100*time.Millisecond)))
+	require.True(t, bucket.allow(now.Add(600*time.Millisecond)), "Expected a token after half a second")
+	require.True(t, bucket.allow(now.Add(10*time.Second)))
+	require.True(t, bucket.allow(now.Add(10*time.Second)))
+	require.False(t, bucket.allow(now.Add(10*time.Second)), "Expected the bucket to hold at most a second's worth")
+}
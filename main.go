@@ -10,10 +10,14 @@ import (
 	"os/signal"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
+	// the image is built from scratch, so the zone database for ?tz= has to be embedded
+	_ "time/tzdata"
+
 	"github.com/alexflint/go-arg"
 	"github.com/amir20/dozzle/internal/analytics"
 	"github.com/amir20/dozzle/internal/auth"
@@ -29,20 +33,40 @@ var (
 )
 
 type args struct {
-	Addr                 string              `arg:"env:DOZZLE_ADDR" default:":8080" help:"sets host:port to bind for server. This is rarely needed inside a docker container."`
-	Base                 string              `arg:"env:DOZZLE_BASE" default:"/" help:"sets the base for http router."`
-	Hostname             string              `arg:"env:DOZZLE_HOSTNAME" help:"sets the hostname for display. This is useful with multiple Dozzle instances."`
-	Level                string              `arg:"env:DOZZLE_LEVEL" default:"info" help:"set Dozzle log level. Use debug for more logging."`
-	AuthProvider         string              `arg:"--auth-provider,env:DOZZLE_AUTH_PROVIDER" default:"none" help:"sets the auth provider to use. Currently only forward-proxy is supported."`
-	AuthHeaderUser       string              `arg:"--auth-header-user,env:DOZZLE_AUTH_HEADER_USER" default:"Remote-User" help:"sets the HTTP Header to use for username in Forward Proxy configuration."`
-	AuthHeaderEmail      string              `arg:"--auth-header-email,env:DOZZLE_AUTH_HEADER_EMAIL" default:"Remote-Email" help:"sets the HTTP Header to use for email in Forward Proxy configuration."`
-	AuthHeaderName       string              `arg:"--auth-header-name,env:DOZZLE_AUTH_HEADER_NAME" default:"Remote-Name" help:"sets the HTTP Header to use for name in Forward Proxy configuration."`
-	WaitForDockerSeconds int                 `arg:"--wait-for-docker-seconds,env:DOZZLE_WAIT_FOR_DOCKER_SECONDS" help:"wait for docker to be available for at most this many seconds before starting the server."`
-	EnableActions        bool                `arg:"--enable-actions,env:DOZZLE_ENABLE_ACTIONS" default:"false" help:"enables essential actions on containers from the web interface."`
-	FilterStrings        []string            `arg:"env:DOZZLE_FILTER,--filter,separate" help:"filters docker containers using Docker syntax."`
-	Filter               map[string][]string `arg:"-"`
-	RemoteHost           []string            `arg:"env:DOZZLE_REMOTE_HOST,--remote-host,separate" help:"list of hosts to connect remotely"`
-	NoAnalytics          bool                `arg:"--no-analytics,env:DOZZLE_NO_ANALYTICS" help:"disables anonymous analytics"`
+	Addr                  string              `arg:"env:DOZZLE_ADDR" default:":8080" help:"sets host:port to bind for server. This is rarely needed inside a docker container."`
+	Base                  string              `arg:"env:DOZZLE_BASE" default:"/" help:"sets the base for http router."`
+	Hostname              string              `arg:"env:DOZZLE_HOSTNAME" help:"sets the hostname for display. This is useful with multiple Dozzle instances."`
+	Level                 string              `arg:"env:DOZZLE_LEVEL" default:"info" help:"set Dozzle log level. Use debug for more logging."`
+	AuthProvider          string              `arg:"--auth-provider,env:DOZZLE_AUTH_PROVIDER" default:"none" help:"sets the auth provider to use. Currently only forward-proxy is supported."`
+	AuthHeaderUser        string              `arg:"--auth-header-user,env:DOZZLE_AUTH_HEADER_USER" default:"Remote-User" help:"sets the HTTP Header to use for username in Forward Proxy configuration."`
+	AuthHeaderEmail       string              `arg:"--auth-header-email,env:DOZZLE_AUTH_HEADER_EMAIL" default:"Remote-Email" help:"sets the HTTP Header to use for email in Forward Proxy configuration."`
+	AuthHeaderName        string              `arg:"--auth-header-name,env:DOZZLE_AUTH_HEADER_NAME" default:"Remote-Name" help:"sets the HTTP Header to use for name in Forward Proxy configuration."`
+	WaitForDockerSeconds  int                 `arg:"--wait-for-docker-seconds,env:DOZZLE_WAIT_FOR_DOCKER_SECONDS" help:"wait for docker to be available for at most this many seconds before starting the server."`
+	EnableActions         bool                `arg:"--enable-actions,env:DOZZLE_ENABLE_ACTIONS" default:"false" help:"enables essential actions on containers from the web interface."`
+	EnableExport          bool                `arg:"--enable-export,env:DOZZLE_ENABLE_EXPORT" default:"false" help:"enables uploading logs to a pre-signed url such as S3."`
+	ExportAllowedHosts    []string            `arg:"env:DOZZLE_EXPORT_ALLOWED_HOSTS,--export-allowed-host,separate" help:"list of hosts exports can be uploaded to, which may be private. Without it only public addresses are allowed."`
+	FilterStrings         []string            `arg:"env:DOZZLE_FILTER,--filter,separate" help:"filters docker containers using Docker syntax."`
+	Filter                map[string][]string `arg:"-"`
+	RemoteHost            []string            `arg:"env:DOZZLE_REMOTE_HOST,--remote-host,separate" help:"list of hosts to connect remotely"`
+	NoAnalytics           bool                `arg:"--no-analytics,env:DOZZLE_NO_ANALYTICS" help:"disables anonymous analytics"`
+	KeepAliveInterval     time.Duration       `arg:"--keep-alive-interval,env:DOZZLE_KEEP_ALIVE_INTERVAL" default:"5s" help:"sets the interval between keep-alive pings on log streams."`
+	DownloadBufferLimit   int                 `arg:"--download-buffer-limit,env:DOZZLE_DOWNLOAD_BUFFER_LIMIT" default:"10485760" help:"sets the maximum size in bytes of buffered downloads."`
+	DownloadMaxBytes      int64               `arg:"--download-max-bytes,env:DOZZLE_DOWNLOAD_MAX_BYTES" default:"0" help:"sets the maximum size in bytes of the logs in a download. Use 0 for no limit."`
+	StreamRetries         int                 `arg:"--stream-retries,env:DOZZLE_STREAM_RETRIES" default:"3" help:"sets how many times a log stream reconnects after a Docker error."`
+	DownloadRetries       int                 `arg:"--download-retries,env:DOZZLE_DOWNLOAD_RETRIES" default:"2" help:"sets how many times opening the logs of a download is retried after a Docker error."`
+	MaxDownloads          int                 `arg:"--max-downloads,env:DOZZLE_MAX_DOWNLOADS" default:"4" help:"sets how many log downloads can run at once. Use 0 for no limit."`
+	MaxClientStreams      int                 `arg:"--max-client-streams,env:DOZZLE_MAX_CLIENT_STREAMS" default:"0" help:"sets how many log streams one user or address can have open at once. Use 0 for no limit."`
+	SSERetry              time.Duration       `arg:"--sse-retry,env:DOZZLE_SSE_RETRY" default:"3s" help:"sets how long browsers wait before reconnecting a log stream."`
+	DownloadFilename      string              `arg:"--download-filename,env:DOZZLE_DOWNLOAD_FILENAME" default:"{name}-{date}" help:"sets the download filename template. Supports {name}, {id} and {date}."`
+	StreamQueueSize       int                 `arg:"--stream-queue-size,env:DOZZLE_STREAM_QUEUE_SIZE" default:"1000" help:"sets how many events are held for a slow client before the oldest are dropped."`
+	StreamLabels          []string            `arg:"env:DOZZLE_STREAM_LABELS,--stream-labels,separate" help:"list of container labels to include when streaming logs"`
+	SnapshotMaxContainers int                 `arg:"--snapshot-max-containers,env:DOZZLE_SNAPSHOT_MAX_CONTAINERS" default:"50" help:"sets how many running containers a snapshot download can include. Use 0 for no limit."`
+	SnapshotMaxBytes      int64               `arg:"--snapshot-max-bytes,env:DOZZLE_SNAPSHOT_MAX_BYTES" default:"536870912" help:"sets the largest snapshot download in bytes. Use 0 for no limit."`
+	LevelPattern          string              `arg:"--level-pattern,env:DOZZLE_LEVEL_PATTERN" help:"sets a regex that extracts the log level of a line, from a group named level or the first group."`
+	StreamReadTimeout     time.Duration       `arg:"--stream-read-timeout,env:DOZZLE_STREAM_READ_TIMEOUT" default:"0s" help:"sets how long a log stream waits for Docker to send anything before closing. Use 0 for no timeout."`
+	CorsOrigins           []string            `arg:"env:DOZZLE_CORS_ORIGINS,--cors-origin,separate" help:"list of origins allowed to read log streams and downloads from another site"`
+	DefaultStdTypes       string              `arg:"--default-std-types,env:DOZZLE_DEFAULT_STD_TYPES" default:"all" help:"sets the streams of log requests without stdout or stderr: all, stdout or stderr. Use strict to reject them."`
+	EventBufferSize       int                 `arg:"--event-buffer-size,env:DOZZLE_EVENT_BUFFER_SIZE" default:"0" help:"sets how many parsed log events are buffered per reader. Higher values trade memory for throughput on busy containers."`
 
 	Healthcheck *HealthcheckCmd `arg:"subcommand:healthcheck" help:"checks if the server is running"`
 	Generate    *GenerateCmd    `arg:"subcommand:generate" help:"generates a configuration file for simple auth"`
@@ -99,6 +123,47 @@ func main() {
 		log.Fatalf("Invalid auth provider %s", args.AuthProvider)
 	}
 
+	if args.KeepAliveInterval <= 0 {
+		log.Fatalf("Invalid keep-alive interval %s, it must be greater than zero", args.KeepAliveInterval)
+	}
+
+	if args.StreamQueueSize <= 0 {
+		log.Fatalf("Invalid stream queue size %d, it must be greater than zero", args.StreamQueueSize)
+	}
+
+	if args.DownloadRetries < 0 {
+		log.Fatalf("Invalid download retries %d, it must not be negative", args.DownloadRetries)
+	}
+
+	if args.MaxClientStreams < 0 {
+		log.Fatalf("Invalid max client streams %d, it must not be negative", args.MaxClientStreams)
+	}
+
+	if args.StreamReadTimeout < 0 {
+		log.Fatalf("Invalid stream read timeout %s, it must not be negative", args.StreamReadTimeout)
+	}
+
+	if args.EventBufferSize < 0 {
+		log.Fatalf("Invalid event buffer size %d, it must not be negative", args.EventBufferSize)
+	}
+
+	if args.DefaultStdTypes != "strict" && docker.ParseStdType(args.DefaultStdTypes) == docker.UNKNOWN {
+		log.Fatalf("Invalid default std types %s, it must be all, stdout, stderr or strict", args.DefaultStdTypes)
+	}
+
+	// compiled once here, the server gets the pattern that was validated
+	var levelPattern *regexp.Regexp
+	if args.LevelPattern != "" {
+		var err error
+		if levelPattern, err = regexp.Compile(args.LevelPattern); err != nil {
+			log.Fatalf("Invalid level pattern: %v", err)
+		}
+	}
+
+	if err := web.ValidateFilenameTemplate(args.DownloadFilename); err != nil {
+		log.Fatalf("Invalid download filename: %v", err)
+	}
+
 	log.Infof("Dozzle version %s", version)
 
 	clients := createClients(args, docker.NewClientWithFilters, docker.NewClientWithTlsAndFilter, args.Hostname)
@@ -109,7 +174,7 @@ func main() {
 		log.Infof("Connected to %d Docker Engine(s)", len(clients))
 	}
 
-	srv := createServer(args, clients)
+	srv := createServer(args, clients, levelPattern)
 	go doStartEvent(args, clients)
 	go func() {
 		log.Infof("Accepting connections on %s", srv.Addr)
@@ -193,7 +258,7 @@ func createClients(args args,
 	return clients
 }
 
-func createServer(args args, clients map[string]docker.Client) *http.Server {
+func createServer(args args, clients map[string]docker.Client, levelPattern *regexp.Regexp) *http.Server {
 	_, dev := os.LookupEnv("DEV")
 
 	var provider web.AuthProvider = web.NONE
@@ -230,7 +295,32 @@ func createServer(args args, clients map[string]docker.Client) *http.Server {
 			Provider:   provider,
 			Authorizer: authorizer,
 		},
-		EnableActions: args.EnableActions,
+		EnableActions:          args.EnableActions,
+		EnableExport:           args.EnableExport,
+		ExportAllowedHosts:     args.ExportAllowedHosts,
+		KeepAliveInterval:      args.KeepAliveInterval,
+		DownloadBufferLimit:    args.DownloadBufferLimit,
+		DownloadMaxBytes:       args.DownloadMaxBytes,
+		StreamRetries:          args.StreamRetries,
+		DownloadRetries:        args.DownloadRetries,
+		MaxConcurrentDownloads: args.MaxDownloads,
+		MaxClientStreams:       args.MaxClientStreams,
+		SSERetry:               args.SSERetry,
+		DownloadFilename:       args.DownloadFilename,
+		StreamQueueSize:        args.StreamQueueSize,
+		StreamLabels:           args.StreamLabels,
+		SnapshotMaxContainers:  args.SnapshotMaxContainers,
+		SnapshotMaxBytes:       args.SnapshotMaxBytes,
+		EventBufferSize:        args.EventBufferSize,
+		CorsOrigins:            args.CorsOrigins,
+		StreamReadTimeout:      args.StreamReadTimeout,
+		LevelPattern:           levelPattern,
+	}
+
+	if args.DefaultStdTypes == "strict" {
+		config.StrictStdTypes = true
+	} else {
+		config.DefaultStdTypes = docker.ParseStdType(args.DefaultStdTypes)
 	}
 
 	assets, err := fs.Sub(content, "dist")